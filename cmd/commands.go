@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,7 +20,7 @@ import (
 
 // --- Task Management Functions ---
 
-var disableFzf, enableTui bool
+var disableFzf, enableTui, useSQLite, allLists bool
 
 func init() {
 	for i := 1; i < len(os.Args); i++ {
@@ -35,89 +38,100 @@ func init() {
 	}
 } // TODO: launch tea.Program(model)
 
-func AddTask(text, due string) error {
-	return todo.AddTaskWithDueDate(text, due)
+func AddTask(store todo.Store, text, due string) error {
+	return todo.AddTaskWithDueDate(store, text, due)
 }
 
-func handleList() {
-	args := os.Args[2:]
-	useJSON := false
-	filter := struct {
-		Done     bool
-		Pending  bool
-		Tag      string
-		Priority string
-		Today    bool
-		Overdue  bool
-	}{
-		Done: false, Pending: false, Tag: "", Priority: "", Today: false, Overdue: false,
-	}
-
-	for _, arg := range args {
+// listQuery is what the todo list filter flags ask for. todo export takes
+// the same flags.
+type listQuery struct {
+	filter   todo.ListFilterOptions
+	asOf     string
+	archived bool
+	json     bool
+	notes    bool // list only: show notes under each task
+}
+
+// parseListFlags reads the filter flags of todo list from args
+func parseListFlags(args []string) listQuery {
+	var q listQuery
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch {
+		case arg == "--as-of" && i+1 < len(args):
+			i++
+			q.asOf = args[i]
+		case strings.HasPrefix(arg, "--as-of="):
+			q.asOf = strings.TrimPrefix(arg, "--as-of=")
 		case arg == "--json":
-			useJSON = true
+			q.json = true
+		case arg == "--archived":
+			q.archived = true
+		case arg == "--notes":
+			q.notes = true
 		case arg == "--done":
-			filter.Done = true
+			q.filter.ShowDone = true
 		case arg == "--pending":
-			filter.Pending = true
+			q.filter.ShowPending = true
 		case arg == "--today":
-			filter.Today = true
+			q.filter.TodayOnly = true
 		case arg == "--overdue":
-			filter.Overdue = true
+			q.filter.OverdueOnly = true
 		case strings.HasPrefix(arg, "--tag="):
-			filter.Tag = strings.TrimPrefix(arg, "--tag=")
+			q.filter.Tag = strings.TrimPrefix(arg, "--tag=")
 		case strings.HasPrefix(arg, "--priority="):
-			filter.Priority = strings.TrimPrefix(arg, "--priority=")
+			q.filter.Priority = strings.TrimPrefix(arg, "--priority=")
 		}
 	}
+	return q
+}
+
+// run finds the tasks q asks for. With --all-lists, names[i] is the list
+// tasks[i] came from; fromArchive[i] is set when it came from the archive.
+func (q listQuery) run(store todo.Store) (tasks []todo.Task, names []string, fromArchive []bool, err error) {
+	switch {
+	case allLists:
+		return queryAllLists(q.filter, q.archived)
+	case q.asOf != "":
+		tasks, err = tasksAsOf(q.asOf)
+		return todo.FilterTasks(tasks, q.filter), nil, nil, err
+	}
+	tasks, fromArchive, err = queryList(store, dataFile, q.filter, q.archived)
+	return tasks, nil, fromArchive, err
+}
 
-	tasks, err := todo.LoadTasks()
+func handleList(store todo.Store) {
+	q := parseListFlags(os.Args[2:])
+	filtered, names, fromArchive, err := q.run(store)
 	if err != nil {
 		fmt.Println("❌ Failed to load tasks:", err)
 		return
 	}
 
-	filtered := []todo.Task{}
-	today := time.Now().Format("2006-01-02")
-	for _, task := range tasks {
-		if filter.Done && !task.Completed {
-			continue
-		}
-		if filter.Pending && task.Completed {
-			continue
-		}
-		if filter.Tag != "" && !contains(task.Tags, filter.Tag) {
-			continue
-		}
-		if filter.Priority != "" && strings.ToLower(task.Priority) != filter.Priority {
-			continue
-		}
-		if filter.Today && task.DueDate != today {
-			continue
-		}
-		if filter.Overdue {
-			if task.DueDate == "" {
-				continue
-			}
-			due, err := time.Parse("2006-01-02", task.DueDate)
-			if err != nil || !time.Now().After(due) {
-				continue
+	if q.json {
+		var out any = filtered
+		if allLists {
+			byList := map[string][]todo.Task{}
+			for i, task := range filtered {
+				byList[names[i]] = append(byList[names[i]], task)
 			}
+			out = byList
 		}
-		filtered = append(filtered, task)
-	}
-
-	if useJSON {
-		jsonBytes, _ := json.MarshalIndent(filtered, "", "  ")
+		jsonBytes, _ := json.MarshalIndent(out, "", "  ")
 		fmt.Println(string(jsonBytes))
 		return
 	}
 
-	for _, task := range filtered {
+	for i, task := range filtered {
 		label := fmt.Sprintf("%d: %s", task.ID, task.Text)
+		if allLists {
+			label = names[i] + "/" + label
+		}
+		if task.Parent != "" {
+			label = "↳ " + label
+		}
 		if task.DueDate != "" {
-			label += fmt.Sprintf(" (Due: %s)", task.DueDate)
+			label += fmt.Sprintf(" (Due: %s)", task.DueLabel())
 		}
 		if task.Recurring != "" {
 			label += fmt.Sprintf(" 🔁 %s", task.Recurring)
@@ -125,6 +139,9 @@ func handleList() {
 		if len(task.Tags) > 0 {
 			label += " " + strings.Join(task.Tags, " ")
 		}
+		if fromArchive != nil && fromArchive[i] {
+			label += " 📦"
+		}
 		switch {
 		case task.Completed:
 			fmt.Println(color.GreenString("[✓] " + label))
@@ -133,16 +150,47 @@ func handleList() {
 		default:
 			fmt.Println(color.CyanString("[ ] " + label))
 		}
+		if q.notes {
+			for _, note := range task.Notes {
+				fmt.Println("      📝 " + note.Text)
+			}
+		}
 	}
 }
 
-func contains(tags []string, tag string) bool {
-	for _, t := range tags {
-		if strings.EqualFold(t, tag) {
-			return true
-		}
+// queryList runs filter over a list and, with archived, its archive too.
+// fromArchive[i] is set when tasks[i] came from the archive.
+func queryList(store todo.Store, path string, filter todo.ListFilterOptions, archived bool) (tasks []todo.Task, fromArchive []bool, err error) {
+	tasks, err = todo.QueryTasks(store, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	fromArchive = make([]bool, len(tasks))
+	if !archived {
+		return tasks, fromArchive, nil
 	}
-	return false
+	old, err := archiveFor(path).Tasks()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, t := range todo.FilterTasks(old, filter) {
+		tasks = append(tasks, t)
+		fromArchive = append(fromArchive, true)
+	}
+	return tasks, fromArchive, nil
+}
+
+// tasksAsOf rebuilds the list as it stood at the end of the given day
+func tasksAsOf(date string) ([]todo.Task, error) {
+	at, err := todo.EndOfDay(date)
+	if err != nil {
+		return nil, err
+	}
+	journal := journalFor(dataFile)
+	if start, err := journal.Start(); err == nil && (start.IsZero() || at.Before(start)) {
+		fmt.Println(color.YellowString("⚠️  History only goes back to %s.", start.Local().Format("2006-01-02 15:04")))
+	}
+	return journal.StateAt(at)
 }
 
 func isOverdue(date string) bool {
@@ -150,33 +198,58 @@ func isOverdue(date string) bool {
 	return err == nil && time.Now().After(due)
 }
 
-func MarkTaskDone(input string) error {
-	return todo.MarkTaskDone(input)
+func MarkTaskDone(store todo.Store, input string) error {
+	return todo.MarkTaskDone(store, input)
 }
 
-func SetDueDate(input, dueDate string) error {
-	return todo.SetDueDate(input, dueDate)
+func SetDueDate(store todo.Store, input, dueDate string) error {
+	return todo.SetDueDate(store, input, dueDate)
 }
 
-func DeleteTask(input string) error {
-	return todo.DeleteTask(input)
+func DeleteTask(store todo.Store, input string) error {
+	return todo.DeleteTask(store, input)
 }
 
-func ClearTasks() error {
-	return todo.ClearTasks()
+func ClearTasks(store todo.Store) error {
+	return todo.ClearTasks(store)
 }
 
-func ResetTasks() error {
-	return os.Remove("todo/tasks.json")
+func ResetTasks(path string) error {
+	return os.Remove(path)
 }
 
-func SearchTasks(keyword string) {
-	todo.SearchTasks(keyword)
+func SearchTasks(store todo.Store, keyword string) {
+	todo.SearchTasks(store, keyword)
 }
 
 // --- CLI Command Dispatcher ---
 
-func HandleCommands() {
+// aliases are the short names of commands
+var aliases = map[string]string{
+	"a":      "add",
+	"ls":     "list",
+	"d":      "done",
+	"rm":     "delete",
+	"del":    "delete",
+	"clr":    "clear",
+	"r":      "reset",
+	"s":      "search",
+	"h":      "help",
+	"?":      "help",
+	"-h":     "help",
+	"--help": "help",
+}
+
+// commandName is the command an argument names, with aliases resolved
+func commandName(arg string) string {
+	cmd := strings.ToLower(arg)
+	if real, ok := aliases[cmd]; ok {
+		return real
+	}
+	return cmd
+}
+
+func HandleCommands(store todo.Store) {
 	// Parse flags
 	for i := 1; i < len(os.Args); i++ {
 		if os.Args[i] == "--no-fzf" {
@@ -193,7 +266,7 @@ func HandleCommands() {
 
 	// 🧃 Launch TUI if enabled
 	if enableTui {
-		StartTUI()
+		StartTUI(store)
 		return
 	}
 
@@ -202,51 +275,75 @@ func HandleCommands() {
 		return
 	}
 
-	aliases := map[string]string{
-		"a":      "add",
-		"ls":     "list",
-		"d":      "done",
-		"rm":     "delete",
-		"del":    "delete",
-		"clr":    "clear",
-		"r":      "reset",
-		"s":      "search",
-		"h":      "help",
-		"?":      "help",
-		"-h":     "help",
-		"--help": "help",
-	}
-
-	cmd := strings.ToLower(os.Args[1])
-	if real, ok := aliases[cmd]; ok {
-		cmd = real
-	}
+	cmd := commandName(os.Args[1])
 
 	switch cmd {
 	case "add":
-		handleAdd()
+		handleAdd(store)
 	case "edit":
-		handleEdit()
+		handleEdit(store)
 	case "list":
-		handleList()
+		handleList(store)
 	case "done":
-		handleDone()
+		handleDone(store)
 	case "due":
-		handleDue()
+		handleDue(store)
 	case "delete":
-		handleDelete()
+		handleDelete(store)
 	case "clear":
-		handleClear()
+		handleClear(store)
 	case "reset":
-		handleReset()
+		handleReset(store)
 	case "search":
-		handleSearch()
+		handleSearch(store)
+	case "migrate":
+		handleMigrate()
+	case "renumber":
+		handleRenumber(store)
+	case "undo":
+		handleUndo(store)
+	case "redo":
+		handleRedo(store)
+	case "log":
+		handleLog(store)
+	case "rebuild":
+		handleRebuild(store)
+	case "diff":
+		handleDiff()
+	case "trash":
+		handleTrash(store)
+	case "restore":
+		handleRestore(store)
+	case "backup":
+		handleBackup(store)
+	case "history":
+		handleHistory()
+	case "archive":
+		handleArchive(store)
+	case "sync":
+		handleSync(store)
+	case "serve":
+		handleServe(store)
+	case "export":
+		handleExport(store)
+	case "import":
+		handleImport(store)
+	case "lists":
+		handleLists()
+	case "move":
+		handleMove(store)
+	case "encrypt":
+		handleEncrypt(store)
+	case "decrypt":
+		handleDecrypt(store)
+	case "lock":
+		handleLock()
 	case "tag":
-		handleTags()
+		handleTags(store)
 	case "help":
 		printHelp()
 	case "tui":
-		StartTUI()
+		StartTUI(store)
 
 	default:
 		fmt.Println("❌ Unknown command:", cmd)
@@ -256,8 +353,8 @@ func HandleCommands() {
 
 // --- FZF Selector ---
 
-func selectTasksWithFzf(multi bool) ([]todo.Task, error) {
-	tasks, err := todo.LoadTasks()
+func selectTasksWithFzf(store todo.Store, multi bool) ([]todo.Task, error) {
+	tasks, err := store.Load()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load tasks: %w", err)
 	}
@@ -283,22 +380,20 @@ func selectTasksWithFzf(multi bool) ([]todo.Task, error) {
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(input)
-	id, err := strconv.Atoi(input)
-	if err != nil {
+	if input == "" {
 		return nil, fmt.Errorf("invalid ID")
 	}
 
-	for _, t := range tasks {
-		if t.ID == id {
-			return []todo.Task{t}, nil
-		}
+	i, err := todo.ResolveTask(tasks, input)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("task not found")
+	return []todo.Task{tasks[i]}, nil
 }
 
 // --- Handlers ---
 
-func handleAdd() {
+func handleAdd(store todo.Store) {
 	if len(os.Args) < 3 {
 		fmt.Println("Usage: todo add [task text] [optional due date]")
 		return
@@ -308,13 +403,13 @@ func handleAdd() {
 	if len(os.Args) > 3 {
 		due = strings.Join(os.Args[3:], " ")
 	}
-	if err := AddTask(text, due); err != nil {
+	if err := AddTask(store, text, due); err != nil {
 		fmt.Println("Error:", err)
 	}
 }
 
-func handleEdit() {
-	selected, err := selectTasksWithFzf(false)
+func handleEdit(store todo.Store) {
+	selected, err := selectTasksWithFzf(store, false)
 	if err != nil || len(selected) == 0 {
 		fmt.Println("Select error:", err)
 		return
@@ -329,58 +424,72 @@ func handleEdit() {
 		fmt.Println("No changes made.")
 		return
 	}
-	if err := todo.EditTaskText(strconv.Itoa(task.ID), newText); err != nil {
+	if err := todo.EditTaskText(store, strconv.Itoa(task.ID), newText); err != nil {
 		fmt.Println("Edit error:", err)
 	}
 }
 
-func handleDone() {
-	selected, err := selectTasksWithFzf(true)
+func handleDone(store todo.Store) {
+	selected, err := selectTasksWithFzf(store, true)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
 	for _, task := range selected {
-		if err := MarkTaskDone(strconv.Itoa(task.ID)); err != nil {
+		if err := MarkTaskDone(store, strconv.Itoa(task.ID)); err != nil {
 			fmt.Println("❌", err)
 		}
 	}
 }
 
-func handleDelete() {
-	selected, err := selectTasksWithFzf(true)
+func handleDelete(store todo.Store) {
+	selected, err := selectTasksWithFzf(store, true)
 	if err != nil {
 		fmt.Println("Error selecting task:", err)
 		return
 	}
-	for _, task := range selected {
-		if err := todo.DeleteTask(strconv.Itoa(task.ID)); err != nil {
-			fmt.Println("❌", err)
-		}
+	ids := make([]string, len(selected))
+	for i, task := range selected {
+		ids[i] = strconv.Itoa(task.ID)
+	}
+	if err := todo.DeleteTasks(store, ids); err != nil {
+		fmt.Println("❌", err)
 	}
 }
 
-func handleDue() {
+func handleDue(store todo.Store) {
 	if len(os.Args) < 4 {
 		fmt.Println("Usage: todo due [task ID or task text] [date]")
 		return
 	}
 	input := os.Args[2]
 	dueDate := strings.Join(os.Args[3:], " ")
-	if err := SetDueDate(input, dueDate); err != nil {
+	if err := SetDueDate(store, input, dueDate); err != nil {
 		fmt.Println("Error:", err)
 	}
 }
 
-func handleSearch() {
+func handleSearch(store todo.Store) {
 	if len(os.Args) < 3 {
 		fmt.Println("Usage: todo search [keyword]")
 		return
 	}
-	SearchTasks(os.Args[2])
+	keyword, archived := os.Args[2], false
+	for _, arg := range os.Args[2:] {
+		if arg == "--archived" {
+			archived = true
+		} else {
+			keyword = arg
+		}
+	}
+	if allLists || archived {
+		searchTasks(store, keyword, archived)
+		return
+	}
+	SearchTasks(store, keyword)
 }
-func handleTags() {
-	tasks, err := selectTasksWithFzf(false)
+func handleTags(store todo.Store) {
+	tasks, err := selectTasksWithFzf(store, false)
 	if err != nil || len(tasks) == 0 {
 		fmt.Println("Error selecting task:", err)
 		return
@@ -406,41 +515,456 @@ func handleTags() {
 		}
 	}
 
-	all, err := todo.LoadTasks()
+	if err := todo.SetTags(store, task.ID, tags); err != nil {
+		fmt.Println("Error saving tasks:", err)
+		return
+	}
+
+	fmt.Println("✅ Tags updated.")
+}
+
+func handleClear(store todo.Store) {
+	if err := ClearTasks(store); err != nil {
+		fmt.Println("Error:", err)
+	} else {
+		fmt.Println("✅ All tasks cleared. `todo trash` lists them, `todo restore` brings one back.")
+	}
+}
+
+func handleReset(store todo.Store) {
+	if tasks, err := store.Load(); err == nil {
+		if err := backupsFor(dataFile).Snapshot(tasks, "reset"); err != nil {
+			fmt.Println("⚠️ Backup before reset failed:", err)
+			return
+		}
+	}
+	if err := ResetTasks(dataFile); err != nil {
+		fmt.Println("⚠️ Reset failed:", err)
+	} else {
+		fmt.Println("🗑️", dataFile, "deleted.")
+	}
+}
+
+func handleMigrate() {
+	from, to := jsonPath(dataFile), sqlitePath(dataFile)
+	db, err := todo.NewSQLiteStore(to)
+	if err != nil {
+		fmt.Println("❌ Failed to open database:", err)
+		return
+	}
+	defer db.Close()
+
+	src := todo.NewJSONStore(from)
+	src.Encryption = encryptionFor(from)
+	n, err := todo.MigrateTasks(src, db)
 	if err != nil {
-		fmt.Println("Error loading tasks:", err)
+		fmt.Println("❌ Migration failed:", err)
 		return
 	}
+	fmt.Printf("✅ Migrated %d tasks from %s to %s. Use --sqlite to work with it.\n", n, from, to)
+}
 
-	for i, t := range all {
-		if t.ID == task.ID {
-			all[i].Tags = tags
-			break
+func handleRenumber(store todo.Store) {
+	changed, err := todo.RenumberTasks(store)
+	if err != nil {
+		fmt.Println("❌ Renumber failed:", err)
+		return
+	}
+	if len(changed) == 0 {
+		fmt.Println("IDs are already compact.")
+		return
+	}
+	old := make([]int, 0, len(changed))
+	for id := range changed {
+		old = append(old, id)
+	}
+	sort.Ints(old)
+	for _, id := range old {
+		fmt.Printf("%d → %d\n", id, changed[id])
+	}
+	fmt.Println("✅ Renumbered. UUIDs are unchanged.")
+}
+
+func handleUndo(store todo.Store) {
+	summary, err := historyFor(dataFile).Undo(store)
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	fmt.Println("↩️  Undid", summary)
+}
+
+func handleRedo(store todo.Store) {
+	summary, err := historyFor(dataFile).Redo(store)
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	fmt.Println("↪️  Redid", summary)
+}
+
+func handleLog(store todo.Store) {
+	events, err := journalFor(dataFile).Events()
+	if err != nil {
+		fmt.Println("❌ Failed to read journal:", err)
+		return
+	}
+
+	if len(os.Args) > 2 {
+		uuid, err := findTaskUUID(store, events, os.Args[2])
+		if err != nil {
+			fmt.Println("❌", err)
+			return
 		}
+		events = todo.TaskEvents(events, uuid)
 	}
 
-	if err := todo.SaveTasks(all); err != nil {
-		fmt.Println("Error saving tasks:", err)
+	if len(events) == 0 {
+		fmt.Println("📭 No history yet.")
 		return
 	}
+	for _, e := range events {
+		fmt.Println(e)
+	}
+}
 
-	fmt.Println("✅ Tags updated.")
+// findTaskUUID resolves an ID, text or UUID prefix against the current
+// list first, then against tasks that only exist in the journal (deleted
+// ones, say)
+func findTaskUUID(store todo.Store, events []todo.Event, input string) (string, error) {
+	tasks, err := store.Load()
+	if err != nil {
+		return "", err
+	}
+	if i, err := todo.ResolveTask(tasks, input); err == nil {
+		return tasks[i].UUID, nil
+	}
+
+	seen := map[string]bool{}
+	past := []todo.Task{}
+	for i := len(events) - 1; i >= 0; i-- {
+		if !seen[events[i].UUID] {
+			seen[events[i].UUID] = true
+			past = append(past, events[i].Task)
+		}
+	}
+	i, err := todo.ResolveTask(past, input)
+	if err != nil {
+		return "", err
+	}
+	return past[i].UUID, nil
 }
 
-func handleClear() {
-	if err := ClearTasks(); err != nil {
-		fmt.Println("Error:", err)
-	} else {
-		fmt.Println("✅ All tasks cleared.")
+func handleRebuild(store todo.Store) {
+	tasks, err := journalFor(dataFile).Replay()
+	if err != nil {
+		fmt.Println("❌ Failed to replay journal:", err)
+		return
 	}
+	err = todo.Modify(store, "rebuild", func([]todo.Task) ([]todo.Task, error) {
+		return tasks, nil
+	})
+	if err != nil {
+		fmt.Println("❌ Rebuild failed:", err)
+		return
+	}
+	fmt.Printf("✅ Rebuilt %d tasks from the journal.\n", len(tasks))
 }
 
-func handleReset() {
-	if err := ResetTasks(); err != nil {
-		fmt.Println("⚠️ Reset failed:", err)
+func handleDiff() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: todo diff [date1] [date2]")
+		return
+	}
+	before, err := tasksAsOf(os.Args[2])
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	after, err := tasksAsOf(os.Args[3])
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+
+	d := todo.CompareSnapshots(before, after)
+	if len(d.Added)+len(d.Completed)+len(d.Deleted)+len(d.Redated) == 0 {
+		fmt.Println("No changes between those dates.")
+		return
+	}
+	for _, t := range d.Added {
+		fmt.Println(color.CyanString("+ added     %d: %s", t.ID, t.Text))
+	}
+	for _, t := range d.Completed {
+		fmt.Println(color.GreenString("✓ completed %d: %s", t.ID, t.Text))
+	}
+	for _, t := range d.Deleted {
+		fmt.Println(color.RedString("- deleted   %d: %s", t.ID, t.Text))
+	}
+	for _, r := range d.Redated {
+		fmt.Println(color.YellowString("📅 re-dated %d: %s (%s → %s)", r.After.ID, r.After.Text, orNone(r.Before.DueDate), orNone(r.After.DueDate)))
+	}
+}
+
+func orNone(date string) string {
+	if date == "" {
+		return "none"
+	}
+	return date
+}
+
+func handleServe(store todo.Store) {
+	addr := "localhost:8080"
+	public := false
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--addr" && i+1 < len(args):
+			i++
+			addr = args[i]
+		case strings.HasPrefix(args[i], "--addr="):
+			addr = strings.TrimPrefix(args[i], "--addr=")
+		case args[i] == "--public":
+			public = true
+		}
+	}
+	// The API has no authentication, so anyone who can reach it can
+	// change the list
+	if !public && !isLoopback(addr) {
+		fmt.Printf("❌ %s can be reached from other machines, and the API has no authentication.\n", addr)
+		fmt.Println("Use --addr localhost:8080, or add --public to serve on it anyway.")
+		return
+	}
+
+	srv := todo.NewServer(store)
+	srv.Journal = journalFor(dataFile)
+	srv.Archive = archiveFor(dataFile)
+	fmt.Printf("🌐 Serving %s on http://%s/tasks\n", dataFile, addr)
+	if err := http.ListenAndServe(addr, srv); err != nil {
+		fmt.Println("❌", err)
+	}
+}
+
+// isLoopback reports whether addr only listens on this machine. ":8080"
+// listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func handleArchive(store todo.Store) {
+	olderThan := time.Duration(0)
+	if cfg, err := todo.LoadConfig(); err == nil && cfg.ArchiveAfterDays > 0 {
+		olderThan = time.Duration(cfg.ArchiveAfterDays) * 24 * time.Hour
+	}
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--older-than" && i+1 < len(args):
+			i++
+			arg = "--older-than=" + args[i]
+			fallthrough
+		case strings.HasPrefix(arg, "--older-than="):
+			age, err := todo.ParseAge(strings.TrimPrefix(arg, "--older-than="))
+			if err != nil {
+				fmt.Println("❌", err)
+				return
+			}
+			olderThan = age
+		}
+	}
+
+	n, err := todo.ArchiveTasks(store, time.Now().Add(-olderThan))
+	if err != nil {
+		fmt.Println("❌ Archive failed:", err)
+		return
+	}
+	if n == 0 {
+		fmt.Println("Nothing to archive.")
+		return
+	}
+	fmt.Printf("📦 Archived %d completed tasks. See them with: todo list --done --archived\n", n)
+}
+
+func handleTrash(store todo.Store) {
+	trash := trashFor(dataFile)
+	purge := false
+	olderThan := time.Duration(0)
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--purge":
+			purge = true
+		case arg == "--older-than" && i+1 < len(args):
+			i++
+			arg = "--older-than=" + args[i]
+			fallthrough
+		case strings.HasPrefix(arg, "--older-than="):
+			age, err := todo.ParseAge(strings.TrimPrefix(arg, "--older-than="))
+			if err != nil {
+				fmt.Println("❌", err)
+				return
+			}
+			olderThan = age
+		}
+	}
+
+	if purge {
+		n, err := trash.Purge(store, olderThan)
+		if err != nil {
+			fmt.Println("❌ Purge failed:", err)
+			return
+		}
+		fmt.Printf("🧹 Purged %d tasks from the trash.\n", n)
+		return
+	}
+
+	trashed, err := trash.List()
+	if err != nil {
+		fmt.Println("❌ Failed to read trash:", err)
+		return
+	}
+	if len(trashed) == 0 {
+		fmt.Println("🗑️ Trash is empty.")
+		return
+	}
+	for _, t := range trashed {
+		fmt.Printf("%d: %s %s\n", t.Task.ID, t.Task.Text, color.HiBlackString("(deleted %s)", t.DeletedAt.Local().Format("2006-01-02 15:04")))
+	}
+}
+
+func handleRestore(store todo.Store) {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: todo restore [id|text]")
+		return
+	}
+	task, err := trashFor(dataFile).Restore(store, strings.Join(os.Args[2:], " "))
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	fmt.Printf("♻️  Restored %d: %s\n", task.ID, task.Text)
+}
+
+func handleBackup(store todo.Store) {
+	backups := backupsFor(dataFile)
+	sub := "list"
+	if len(os.Args) > 2 {
+		sub = os.Args[2]
+	}
+
+	switch sub {
+	case "list":
+		all, err := backups.List()
+		if err != nil {
+			fmt.Println("❌ Failed to list backups:", err)
+			return
+		}
+		if len(all) == 0 {
+			fmt.Println("📭 No backups yet.")
+			return
+		}
+		for _, bk := range all {
+			reason := "before " + bk.Op
+			if bk.Op == "daily" {
+				reason = "daily"
+			}
+			fmt.Printf("%s  %s\n", bk.Name, color.HiBlackString("(%s, %s)", bk.Time.Format("2006-01-02 15:04"), reason))
+		}
+
+	case "restore":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: todo backup restore [name]")
+			return
+		}
+		bk, err := backups.Find(os.Args[3])
+		if err != nil {
+			fmt.Println("❌", err)
+			return
+		}
+		saved, err := backups.Load(bk)
+		if err != nil {
+			fmt.Println("❌ Failed to read backup:", err)
+			return
+		}
+		current, err := store.Load()
+		if err != nil {
+			fmt.Println("❌ Failed to load tasks:", err)
+			return
+		}
+
+		diffs := todo.DiffTasks(current, saved)
+		if len(diffs) == 0 {
+			fmt.Println("Backup matches the current list, nothing to do.")
+			return
+		}
+		fmt.Printf("Restoring %s would change %d tasks:\n", bk.Name, len(diffs))
+		printDiffs(diffs)
+		if !confirm("Overwrite the current list?") {
+			fmt.Println("Cancelled.")
+			return
+		}
+		if err := backups.Restore(store, bk); err != nil {
+			fmt.Println("❌ Restore failed:", err)
+			return
+		}
+		fmt.Println("✅ Restored", bk.Name, "(the previous list was backed up first).")
+
+	default:
+		fmt.Println("Usage: todo backup [list|restore name]")
+	}
+}
+
+// printDiffs shows a one-line summary per changed task
+func printDiffs(diffs []todo.TaskDiff) {
+	for _, d := range diffs {
+		switch {
+		case d.Before == nil:
+			fmt.Println(color.GreenString("  + %d: %s", d.After.ID, d.After.Text))
+		case d.After == nil:
+			fmt.Println(color.RedString("  - %d: %s", d.Before.ID, d.Before.Text))
+		default:
+			fmt.Println(color.YellowString("  ~ %d: %s", d.After.ID, d.After.Text))
+		}
+	}
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func handleHistory() {
+	repo := todo.GitRepoFor(baseFile, dataFile)
+	var out string
+	var err error
+	if len(os.Args) > 2 {
+		out, err = repo.Show(os.Args[2])
 	} else {
-		fmt.Println("🗑️ tasks.json deleted.")
+		out, err = repo.Log(0)
+	}
+	if err != nil {
+		fmt.Println("❌", err)
+		fmt.Println(`Enable git history with {"git": true} in the config file.`)
+		return
+	}
+	if strings.TrimSpace(out) == "" {
+		fmt.Println("📭 No commits yet.")
+		return
 	}
+	fmt.Print(out)
 }
 
 // --- Help ---
@@ -451,13 +975,40 @@ func printHelp() {
   todo add [text] [due?]       → Add new task
   todo list                    → List all tasks
   todo done                    → Mark one or more tasks done
-  todo due [id|text] [date]    → Set/change due date
-  todo delete                  → Delete one or more tasks
+  todo due [id|text] [date]    → Set/change due date (id can be a UUID prefix)
+  todo delete                  → Delete one or more tasks (into the trash)
   todo edit                    → Edit a task
   todo search [keyword]        → Search task text
   todo tag                     → Edit task tags
-  todo clear                   → Clear all tasks
-  todo reset                   → Delete tasks.json
+  todo clear                   → Clear all tasks (into the trash)
+  todo trash                   → List deleted tasks
+  todo trash --purge [--older-than 30d] → Empty the trash
+  todo restore [id]            → Bring a task back from the trash
+  todo backup list             → List automatic backups
+  todo backup restore [name]   → Restore a backup (shows what would change first)
+  todo history [rev]           → Browse git history of the task file ("git": true in config)
+  todo archive [--older-than 30d] → Move completed tasks out of the active list
+  todo serve [--addr localhost:8080] [--public] → Serve the task list as a JSON REST API (--public to listen beyond this machine)
+  todo export [file] [--format=todotxt|ics|markdown|csv|taskwarrior] [list filters] → Write the task list in another format (stdout without a file)
+  todo import [file] [--format=todotxt|ics|markdown|csv|taskwarrior] → Add tasks from another format (format from the extension)
+  todo import plan.csv --map "Title=text,Deadline=due" → Say which task field a CSV column holds
+  todo import notes.md --headings=lists → Put each heading's checklist in the list named after it
+  todo sync [file|dir]         → Merge with another copy of the task file
+  todo sync --remote webdav://host/path → Merge with a copy on a WebDAV server
+  todo sync --remote caldav://host/list/ → Sync with a CalDAV task list (phone apps)
+  todo lists                   → Show every list with its task counts
+  todo move [id] [list]        → Move a task to another list
+  todo encrypt                 → Encrypt the task file and everything next to it
+  todo decrypt                 → Turn an encrypted task file back into plain JSON
+  todo lock                    → Forget the cached passphrase key
+  todo reset                   → Delete the task file
+  todo migrate                 → Copy tasks.json into tasks.db (SQLite)
+  todo renumber                → Compact display IDs to 1..n
+  todo undo                    → Undo the last change
+  todo redo                    → Redo the last undone change
+  todo log [id]                → Show the change history of a task or the whole list
+  todo rebuild                 → Rebuild the task list by replaying the journal
+  todo diff [date1] [date2]    → Tasks added, completed, deleted or re-dated between two dates
   todo help                    → Show help
 
 💡 Flags:
@@ -469,7 +1020,14 @@ func printHelp() {
   --today						→ Due today
   --overdue						→ Show overdue tasks
   --json 						→ Output JSON format
+  --archived					→ list/search: include archived tasks
+  --notes						→ list: show task notes under each task
+  --as-of=DATE					→ List tasks as they were on DATE (e.g. yd, "last fri")
   --tui 						→ bubble tea interface
+  --sqlite					→ Use tasks.db (SQLite) instead of tasks.json
+  --file=PATH					→ Task file to use (default: $TODO_FILE, config, $XDG_DATA_HOME/todo/tasks.json)
+  --list=NAME					→ Work on a named list (default: "list" in config, else default)
+  --all-lists					→ list/search across every list, showing the list name
 
 
 🔤 Aliases:
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	todo "todo/todo.int"
+
+	"golang.org/x/term"
+)
+
+// encryptions remembers, per task file, how it is encrypted (nil for
+// plain text), so each file's passphrase is asked at most once
+var encryptions = map[string]*todo.Encryption{}
+
+// encryptionFor returns the encryption of the task file at path
+func encryptionFor(path string) *todo.Encryption {
+	if e, ok := encryptions[path]; ok {
+		return e
+	}
+	var e *todo.Encryption
+	if !isSQLitePath(path) && todo.IsEncrypted(path) {
+		e = todo.NewEncryption(readPassphrase)
+	}
+	encryptions[path] = e
+	return e
+}
+
+// readPassphrase takes the passphrase from $TODO_PASSPHRASE, or asks for
+// it on the terminal
+func readPassphrase() (string, error) {
+	if p := os.Getenv("TODO_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	return promptPassphrase("🔑 Passphrase: ")
+}
+
+func promptPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", todo.ErrEncrypted
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(p), err
+}
+
+// newPassphrase asks for a new passphrase twice, unless it comes from
+// $TODO_PASSPHRASE
+func newPassphrase() (string, error) {
+	if p := os.Getenv("TODO_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	p, err := promptPassphrase("🔑 New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	again, err := promptPassphrase("🔑 Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p != again {
+		return "", errors.New("passphrases don't match")
+	}
+	return p, nil
+}
+
+// The sidecar files share the task file's encryption
+
+func historyFor(path string) *todo.History {
+	h := todo.HistoryFor(path)
+	h.Encryption = encryptionFor(path)
+	return h
+}
+
+func journalFor(path string) *todo.Journal {
+	j := todo.JournalFor(path)
+	j.Encryption = encryptionFor(path)
+	return j
+}
+
+func trashFor(path string) *todo.Trash {
+	t := todo.TrashFor(path)
+	t.Encryption = encryptionFor(path)
+	return t
+}
+
+func archiveFor(path string) *todo.Archive {
+	a := todo.ArchiveFor(path)
+	a.Encryption = encryptionFor(path)
+	return a
+}
+
+func backupsFor(path string) *todo.Backups {
+	b := todo.BackupsFor(path)
+	b.Encryption = encryptionFor(path)
+	return b
+}
+
+func handleEncrypt(store todo.Store) {
+	if isSQLitePath(dataFile) {
+		fmt.Println("❌ Encryption is only supported for JSON task files.")
+		return
+	}
+	if encryptionFor(dataFile) != nil {
+		fmt.Println("🔒 Tasks are already encrypted.")
+		return
+	}
+	pass, err := newPassphrase()
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	enc := todo.NewEncryption(func() (string, error) { return pass, nil })
+	err = todo.WithLock(store, func() error {
+		return todo.ConvertDataFiles(dataFile, nil, enc)
+	})
+	if err != nil {
+		fmt.Println("❌ Encryption failed:", err)
+		return
+	}
+	fmt.Println("🔒 Encrypted", dataFile, "and its history, trash, journal and backups.")
+	if cfg, err := todo.LoadConfig(); err == nil && cfg.Git {
+		fmt.Println("⚠️  Earlier commits still hold the tasks in plain text; git keeps them until its history is rewritten.")
+	}
+}
+
+func handleDecrypt(store todo.Store) {
+	enc := encryptionFor(dataFile)
+	if enc == nil {
+		fmt.Println("🔓 Tasks aren't encrypted.")
+		return
+	}
+	err := todo.WithLock(store, func() error {
+		return todo.ConvertDataFiles(dataFile, enc, nil)
+	})
+	if err != nil {
+		fmt.Println("❌ Decryption failed:", err)
+		return
+	}
+	// The files are plain text now, so the cached key is no longer needed
+	_ = todo.ForgetKeys()
+	fmt.Println("🔓 Decrypted", dataFile, "and its history, trash, journal and backups.")
+}
+
+func handleLock() {
+	if err := todo.ForgetKeys(); err != nil {
+		fmt.Println("❌ Failed to forget cached keys:", err)
+		return
+	}
+	fmt.Println("🔒 Cached keys forgotten. The passphrase will be asked again.")
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	todo "todo/todo.int"
+)
+
+// formatArgs splits the arguments of todo import/export into --format and
+// the one file named, if any
+func formatArgs() (format, file string) {
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--as-of" || args[i] == "--map") && i+1 < len(args):
+			i++ // read by parseListFlags and mapArgs
+		case args[i] == "--format" && i+1 < len(args):
+			i++
+			format = args[i]
+		case strings.HasPrefix(args[i], "--format="):
+			format = strings.TrimPrefix(args[i], "--format=")
+		case (args[i] == "-o" || args[i] == "--output") && i+1 < len(args):
+			i++
+			file = args[i]
+		case strings.HasPrefix(args[i], "--output="):
+			file = strings.TrimPrefix(args[i], "--output=")
+		case !strings.HasPrefix(args[i], "-") || args[i] == "-":
+			file = args[i]
+		}
+	}
+	return format, file
+}
+
+// pickFormat is the --format given, or else the one file's extension
+// implies, or else fallback
+func pickFormat(name, file, fallback string) (todo.Format, error) {
+	switch {
+	case name != "":
+		return todo.FormatNamed(name)
+	case file != "" && file != "-":
+		return todo.FormatForFile(file)
+	}
+	return todo.FormatNamed(fallback)
+}
+
+func handleExport(store todo.Store) {
+	name, file := formatArgs()
+	format, err := pickFormat(name, file, "json")
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	tasks, names, _, err := parseListFlags(os.Args[2:]).run(store)
+	if err != nil {
+		fmt.Println("❌ Failed to load tasks:", err)
+		return
+	}
+	var data []byte
+	switch {
+	case !allLists:
+		data, err = format.Encode(tasks)
+	case format.Name == "markdown":
+		data, err = todo.EncodeMarkdownSections(listSections(tasks, names))
+	case format.Name == "csv":
+		data, err = todo.EncodeCSVLists(tasks, names)
+	default:
+		fmt.Println("❌ --all-lists exports to markdown, a heading per list, or csv, with a list column.")
+		return
+	}
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+
+	if file == "" || file == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	fmt.Printf("📤 Exported %d tasks to %s (%s).\n", len(tasks), file, format.Name)
+}
+
+// listSections groups tasks by the list they came from, names[i] for
+// tasks[i], a Markdown section per list
+func listSections(tasks []todo.Task, names []string) []todo.MarkdownSection {
+	var sections []todo.MarkdownSection
+	for i, t := range tasks {
+		if len(sections) == 0 || sections[len(sections)-1].Heading != names[i] {
+			sections = append(sections, todo.MarkdownSection{Heading: names[i]})
+		}
+		sec := &sections[len(sections)-1]
+		sec.Tasks = append(sec.Tasks, t)
+	}
+	return sections
+}
+
+// mapArgs reads the --map options of todo import into one mapping
+func mapArgs() (todo.CSVMapping, error) {
+	mapping := todo.CSVMapping{}
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		spec := ""
+		switch {
+		case args[i] == "--map" && i+1 < len(args):
+			i++
+			spec = args[i]
+		case strings.HasPrefix(args[i], "--map="):
+			spec = strings.TrimPrefix(args[i], "--map=")
+		default:
+			continue
+		}
+		m, err := todo.ParseCSVMapping(spec)
+		if err != nil {
+			return nil, err
+		}
+		for header, field := range m {
+			mapping[header] = field
+		}
+	}
+	return mapping, nil
+}
+
+func handleImport(store todo.Store) {
+	name, file := formatArgs()
+	headingLists := false
+	for _, arg := range os.Args[2:] {
+		switch arg {
+		case "--headings=lists":
+			headingLists = true
+		case "--headings=tags":
+			headingLists = false
+		}
+	}
+	if file == "" {
+		fmt.Printf("Usage: todo import [file] [--format=%s]\n", strings.Join(todo.FormatNames(), "|"))
+		return
+	}
+	if file == "-" && name == "" {
+		fmt.Println("❌ Reading from stdin needs --format.")
+		return
+	}
+	format, err := pickFormat(name, file, "")
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+
+	var data []byte
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	switch {
+	case headingLists && format.Name == "markdown":
+		importSections(store, file, data)
+		return
+	case format.Name == "csv":
+		importCSV(store, file, data)
+		return
+	}
+	tasks, err := format.Decode(data)
+	if err != nil {
+		fmt.Printf("❌ %s is not valid %s: %v\n", file, format.Name, err)
+		return
+	}
+
+	res, err := todo.ImportTasks(store, tasks)
+	if err != nil {
+		fmt.Println("❌ Import failed:", err)
+		return
+	}
+	printImport(res, file)
+}
+
+// importSections imports a Markdown file a list per heading. Items before
+// the first heading go to the current list.
+func importSections(store todo.Store, file string, data []byte) {
+	sections, err := todo.DecodeMarkdownSections(data)
+	if err != nil {
+		fmt.Printf("❌ %s is not valid markdown: %v\n", file, err)
+		return
+	}
+	for _, sec := range sections {
+		target, label := store, currentList
+		if slug := sec.Slug(); slug != "" {
+			if target, err = openList(slug); err != nil {
+				fmt.Println("❌", err)
+				return
+			}
+			label = slug
+		}
+		res, err := todo.ImportTasks(target, sec.Tasks)
+		if err != nil {
+			fmt.Printf("❌ Import into %s failed: %v\n", label, err)
+			return
+		}
+		printImport(res, file+" → "+label)
+	}
+}
+
+// importCSV imports a CSV file with the --map column mapping. Rows with a
+// list column go to that list, the rest to the current one.
+func importCSV(store todo.Store, file string, data []byte) {
+	mapping, err := mapArgs()
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	tasks, lists, warnings, err := todo.DecodeCSVMapped(data, mapping)
+	if err != nil {
+		fmt.Printf("❌ %s is not valid csv: %v\n", file, err)
+		return
+	}
+	for _, w := range warnings {
+		fmt.Println("⚠️ ", w, "(left out)")
+	}
+	var order []string
+	byList := map[string][]todo.Task{}
+	for i, t := range tasks {
+		if _, ok := byList[lists[i]]; !ok {
+			order = append(order, lists[i])
+		}
+		byList[lists[i]] = append(byList[lists[i]], t)
+	}
+	for _, name := range order {
+		target, label := store, file
+		if name != "" {
+			if target, err = openList(name); err != nil {
+				fmt.Println("❌", err)
+				return
+			}
+			label = file + " → " + name
+		}
+		res, err := todo.ImportTasks(target, byList[name])
+		if err != nil {
+			fmt.Println("❌ Import failed:", err)
+			return
+		}
+		printImport(res, label)
+	}
+	if len(tasks) == 0 {
+		printImport(todo.ImportResult{}, file)
+	}
+}
+
+func printImport(res todo.ImportResult, from string) {
+	fmt.Printf("📥 Imported %d new tasks from %s", res.Added, from)
+	if res.Updated > 0 {
+		fmt.Printf(", updated %d", res.Updated)
+	}
+	if res.Unchanged > 0 {
+		fmt.Printf(", %d already here", res.Unchanged)
+	}
+	fmt.Println(".")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	todo "todo/todo.int"
+
+	"github.com/fatih/color"
+)
+
+// openList opens the named list, tracked like the current one
+func openList(name string) (todo.Store, error) {
+	path, err := todo.ListPath(baseFile, name)
+	if err != nil {
+		return nil, err
+	}
+	return openStore(path)
+}
+
+// queryAllLists runs filter over every list (and with archived, their
+// archives). names[i] is the list that tasks[i] came from.
+func queryAllLists(filter todo.ListFilterOptions, archived bool) (tasks []todo.Task, names []string, fromArchive []bool, err error) {
+	lists, err := todo.ListNames(baseFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, name := range lists {
+		path, err := todo.ListPath(baseFile, name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		store, err := openStore(path)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		found, archivedFlags, err := queryList(store, path, filter, archived)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for range found {
+			names = append(names, name)
+		}
+		tasks = append(tasks, found...)
+		fromArchive = append(fromArchive, archivedFlags...)
+	}
+	return tasks, names, fromArchive, nil
+}
+
+func handleLists() {
+	lists, err := todo.ListNames(baseFile)
+	if err != nil {
+		fmt.Println("❌ Failed to find lists:", err)
+		return
+	}
+	for _, name := range lists {
+		store, err := openList(name)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			continue
+		}
+		tasks, err := store.Load()
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			continue
+		}
+		done := 0
+		for _, t := range tasks {
+			if t.Completed {
+				done++
+			}
+		}
+		marker := " "
+		if name == currentList {
+			marker = "*"
+		}
+		fmt.Printf("%s %-12s %s\n", marker, name, color.HiBlackString("%d pending, %d done", len(tasks)-done, done))
+	}
+}
+
+func handleMove(store todo.Store) {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: todo move [task ID or task text] [list]")
+		return
+	}
+	target := os.Args[len(os.Args)-1]
+	input := strings.Join(os.Args[2:len(os.Args)-1], " ")
+	if target == currentList {
+		fmt.Printf("Task is already in %s.\n", target)
+		return
+	}
+	dest, err := openList(target)
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	moved, err := todo.MoveTask(store, dest, input)
+	if err != nil {
+		fmt.Println("❌ Move failed:", err)
+		return
+	}
+	fmt.Printf("📦 Moved \"%s\" to %s as %d\n", moved.Text, target, moved.ID)
+}
+
+// searchTasks prints the tasks matching keyword in the current list, or
+// every list with --all-lists, and optionally their archives
+func searchTasks(store todo.Store, keyword string, archived bool) {
+	var tasks []todo.Task
+	var names []string
+	var fromArchive []bool
+	var err error
+	if allLists {
+		tasks, names, fromArchive, err = queryAllLists(todo.ListFilterOptions{}, archived)
+	} else {
+		tasks, fromArchive, err = queryList(store, dataFile, todo.ListFilterOptions{}, archived)
+	}
+	if err != nil {
+		fmt.Println("Error loading tasks:", err)
+		return
+	}
+	found := false
+	for i, task := range tasks {
+		if strings.Contains(strings.ToLower(task.Text), strings.ToLower(keyword)) {
+			label := fmt.Sprintf("%d: %s", task.ID, task.Text)
+			if names != nil {
+				label = names[i] + "/" + label
+			}
+			if fromArchive[i] {
+				label += " 📦"
+			}
+			fmt.Println("🔍 " + label)
+			found = true
+		}
+	}
+	if !found {
+		fmt.Println("No matching tasks found.")
+	}
+}
@@ -1,9 +1,26 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	todo "todo/todo.int"
 )
 
+// dataFile is the resolved task file every command works on
+var dataFile string
+
+// baseFile is the default list's task file; named lists live next to it
+var baseFile string
+
+// currentList is the name of the list dataFile holds
+var currentList string
+
+var fileFlag, listFlag string
+
 func init() {
 	for i := 1; i < len(os.Args); i++ {
 		arg := os.Args[i]
@@ -17,9 +34,153 @@ func init() {
 			os.Args = append(os.Args[:i], os.Args[i+1:]...)
 			i--
 		}
+		if arg == "--sqlite" {
+			useSQLite = true
+			os.Args = append(os.Args[:i], os.Args[i+1:]...)
+			i--
+		}
+		if arg == "--all-lists" {
+			allLists = true
+			os.Args = append(os.Args[:i], os.Args[i+1:]...)
+			i--
+			continue
+		}
+		if strings.HasPrefix(arg, "--list=") {
+			listFlag = strings.TrimPrefix(arg, "--list=")
+			os.Args = append(os.Args[:i], os.Args[i+1:]...)
+			i--
+			continue
+		} else if arg == "--list" && i+1 < len(os.Args) {
+			listFlag = os.Args[i+1]
+			os.Args = append(os.Args[:i], os.Args[i+2:]...)
+			i--
+			continue
+		}
+		if strings.HasPrefix(arg, "--file=") {
+			fileFlag = strings.TrimPrefix(arg, "--file=")
+			os.Args = append(os.Args[:i], os.Args[i+1:]...)
+			i--
+		} else if arg == "--file" && i+1 < len(os.Args) {
+			fileFlag = os.Args[i+1]
+			os.Args = append(os.Args[:i], os.Args[i+2:]...)
+			i--
+		}
 	}
 }
 
 func main() {
-	HandleCommands()
+	path, err := todo.ResolveDataFile(fileFlag)
+	if err != nil {
+		fmt.Println("❌ Failed to locate task file:", err)
+		os.Exit(1)
+	}
+	if useSQLite {
+		path = sqlitePath(path)
+	}
+	baseFile = path
+
+	cfg, err := todo.LoadConfig()
+	if err != nil {
+		fmt.Println("❌ Failed to read config:", err)
+		os.Exit(1)
+	}
+	list := listFlag
+	if list == "" {
+		list = cfg.List
+	}
+	if list == "" {
+		list = todo.DefaultList
+	}
+	dataFile, err = todo.ListPath(baseFile, list)
+	if err != nil {
+		fmt.Println("❌", err)
+		os.Exit(1)
+	}
+	currentList = list
+
+	store, err := openStore(dataFile)
+	if err != nil {
+		fmt.Println("❌ Failed to open task store:", err)
+		os.Exit(1)
+	}
+	if cfg.ArchiveAfterDays > 0 && !readOnly(os.Args[1:]) {
+		cutoff := time.Now().AddDate(0, 0, -cfg.ArchiveAfterDays)
+		if _, err := todo.ArchiveTasks(store, cutoff); err != nil {
+			fmt.Println("⚠️  Auto-archive failed:", err)
+		}
+	}
+	HandleCommands(store)
+}
+
+// openStore picks the backend from the file extension and wraps it so
+// every change lands in the undo history and the event journal, deleted
+// tasks go to the trash, archived ones to the archive and destructive
+// changes are backed up first. With "git": true in the config each change
+// is also committed, to the repository of the default task file.
+func openStore(path string) (todo.Store, error) {
+	if err := todo.MigrateSidecars(path); err != nil {
+		return nil, err
+	}
+	var base todo.Store = &todo.JSONStore{Path: path, Encryption: encryptionFor(path)}
+	if isSQLitePath(path) {
+		db, err := todo.NewSQLiteStore(path)
+		if err != nil {
+			return nil, err
+		}
+		base = db
+	}
+	recorders := []todo.Recorder{historyFor(path), journalFor(path), trashFor(path), archiveFor(path), backupsFor(path)}
+
+	cfg, err := todo.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Git {
+		recorders = append(recorders, todo.GitRepoFor(baseFile, path))
+	}
+	return todo.Track(base, recorders...), nil
+}
+
+func isSQLitePath(path string) bool {
+	switch filepath.Ext(path) {
+	case ".db", ".sqlite", ".sqlite3":
+		return true
+	}
+	return false
+}
+
+// sqlitePath maps tasks.json to tasks.db next to it
+func sqlitePath(path string) string {
+	if isSQLitePath(path) {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".db"
+}
+
+// jsonPath maps tasks.db to tasks.json next to it
+func jsonPath(path string) string {
+	if !isSQLitePath(path) {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+}
+
+// readOnly reports whether a command line only looks at tasks, so running
+// it shouldn't archive anything. undo and redo are here too: archiving
+// before them would be what they undo.
+func readOnly(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	switch commandName(args[0]) {
+	case "help", "log", "diff", "history", "lists", "export", "undo", "redo":
+		return true
+	case "list":
+		for _, arg := range args[1:] {
+			if arg == "--as-of" || strings.HasPrefix(arg, "--as-of=") {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	todo "todo/todo.int"
+
+	"github.com/fatih/color"
+)
+
+func handleSync(store todo.Store) {
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--remote" && i+1 < len(args):
+			syncRemote(store, args[i+1])
+			return
+		case strings.HasPrefix(args[i], "--remote="):
+			syncRemote(store, strings.TrimPrefix(args[i], "--remote="))
+			return
+		}
+	}
+	if len(args) < 1 {
+		fmt.Println("Usage: todo sync [other task file or directory] | --remote webdav://host/path | --remote caldav://host/list/")
+		return
+	}
+	other := args[0]
+	if info, err := os.Stat(other); err == nil && info.IsDir() {
+		other = filepath.Join(other, filepath.Base(dataFile))
+	}
+	other, err := filepath.Abs(other)
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	if self, _ := filepath.Abs(dataFile); self == other {
+		fmt.Println("❌ That is the task file itself.")
+		return
+	}
+
+	var remote todo.Store = &todo.JSONStore{Path: other, Encryption: encryptionFor(other)}
+	if isSQLitePath(other) {
+		db, err := todo.NewSQLiteStore(other)
+		if err != nil {
+			fmt.Println("❌ Failed to open", other+":", err)
+			return
+		}
+		defer db.Close()
+		remote = db
+	}
+
+	syncWith(store, remote, other)
+}
+
+// syncRemote syncs with a copy of the task file on a WebDAV server, or
+// with a CalDAV task list
+func syncRemote(store todo.Store, remote string) {
+	if strings.HasPrefix(remote, "caldav") {
+		cal, err := todo.NewCalDAVStore(remote, dataFile)
+		if err != nil {
+			fmt.Println("❌", err)
+			return
+		}
+		cal.Encryption = encryptionFor(dataFile)
+		syncWith(store, cal, cal.URL)
+		return
+	}
+
+	dav, err := todo.NewWebDAVStore(remote, filepath.Base(dataFile))
+	if err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+	dav.Encryption = encryptionFor(dataFile)
+	syncWith(store, dav, dav.URL)
+}
+
+func syncWith(store, remote todo.Store, peer string) {
+	state := todo.SyncStateFor(dataFile)
+	state.Encryption = encryptionFor(dataFile)
+	res, err := todo.Sync(store, remote, state, peer, resolveConflict)
+	if err != nil {
+		fmt.Println("❌ Sync failed:", err)
+		return
+	}
+	if res.Pulled == 0 && res.Pushed == 0 {
+		fmt.Println("✅ Already in sync with", peer)
+		return
+	}
+	fmt.Printf("🔄 Synced with %s: %d changes here, %d there", peer, res.Pulled, res.Pushed)
+	if res.Conflicts > 0 {
+		fmt.Printf(", %d conflicts resolved", res.Conflicts)
+	}
+	fmt.Println(".")
+}
+
+var syncInput = bufio.NewReader(os.Stdin)
+
+// resolveConflict asks which side of a conflict to keep. The side that
+// changed most recently is the default.
+func resolveConflict(c todo.Conflict) todo.Side {
+	newer := c.Newer()
+	hint := "[L/r]"
+	if newer == todo.Remote {
+		hint = "[l/R]"
+	}
+
+	fmt.Println(color.YellowString("⚠️  Conflict on %d: %s (%s)", c.Task.ID, c.Task.Text, c.Field))
+	fmt.Printf("   here:  %s%s\n", c.Local, changedAt(c.LocalTime.IsZero(), c.LocalTime.Local().Format("2006-01-02 15:04")))
+	fmt.Printf("   there: %s%s\n", c.Remote, changedAt(c.RemoteTime.IsZero(), c.RemoteTime.Local().Format("2006-01-02 15:04")))
+	fmt.Printf("Keep which? %s ", hint)
+
+	answer, _ := syncInput.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "l", "local", "here":
+		return todo.Local
+	case "r", "remote", "there":
+		return todo.Remote
+	}
+	return newer
+}
+
+func changedAt(unknown bool, at string) string {
+	if unknown {
+		return ""
+	}
+	return color.HiBlackString("  (changed %s)", at)
+}
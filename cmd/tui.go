@@ -2,9 +2,12 @@ package main
 
 import (
 	// "bufio"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	todo "todo/todo.int"
 
@@ -13,19 +16,62 @@ import (
 )
 
 type model struct {
+	store    todo.Store
 	tasks    []todo.Task
 	cursor   int
 	quitting bool
+
+	// stamp identifies the version of the data file last loaded, so changes
+	// made from another terminal are picked up
+	stamp string
+	// status is a one-line notice shown until the next key press
+	status string
 }
 
+// watchInterval is how often the TUI checks the data file for changes
+const watchInterval = 500 * time.Millisecond
+
+// fileStampMsg carries the data file's current stamp
+type fileStampMsg string
+
 func (m model) Init() tea.Cmd {
-	return nil
+	return watchFile()
+}
+
+// watchFile checks the data file after watchInterval
+func watchFile() tea.Cmd {
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		return fileStampMsg(fileStamp(dataFile))
+	})
+}
+
+// fileStamp summarises the modification time and size of the data file
+// (and SQLite's write-ahead log), which change whenever it is written
+func fileStamp(path string) string {
+	stamp := ""
+	for _, p := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(p); err == nil {
+			stamp += fmt.Sprintf("%d:%d;", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return stamp
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
+	case fileStampMsg:
+		if string(msg) != m.stamp {
+			before := m.tasks
+			m.reload()
+			if !reflect.DeepEqual(before, m.tasks) {
+				m.status = "🔄 Picked up changes made elsewhere"
+			}
+		}
+		return m, watchFile()
+
 	case tea.KeyMsg:
+		m.status = ""
 		switch msg.String() {
 
 		case "ctrl+c", "q":
@@ -42,49 +88,124 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor--
 			}
 
-		case " " , "enter":
-			m.tasks[m.cursor].Completed = !m.tasks[m.cursor].Completed
-			_ = todo.SaveTasks(m.tasks)
+		case " ", "enter":
+			if len(m.tasks) == 0 {
+				break
+			}
+			m.apply("toggle", func(tasks []todo.Task, i int) []todo.Task {
+				tasks[i].SetCompleted(!tasks[i].Completed)
+				return tasks
+			})
 
 		case "x", "backspace":
-			m.tasks = append(m.tasks[:m.cursor], m.tasks[m.cursor+1:]...)
-			if m.cursor >= len(m.tasks) && m.cursor > 0 {
-				m.cursor--
+			if len(m.tasks) == 0 {
+				break
 			}
-			_ = todo.SaveTasks(m.tasks)
+			m.apply("delete", func(tasks []todo.Task, i int) []todo.Task {
+				return append(tasks[:i], tasks[i+1:]...)
+			})
 
 		case "d":
+			if len(m.tasks) == 0 {
+				break
+			}
 			newDue, ok := prompt("📅 Enter new due date:")
 			if ok {
-				parsed, err := todo.ParseNaturalDate(newDue)
-				if err == nil {
-					m.tasks[m.cursor].DueDate = parsed
-					_ = todo.SaveTasks(m.tasks)
+				var due todo.Task
+				if err := due.SetDue(newDue); err != nil {
+					m.status = color.RedString("❌ %v", err)
+				} else {
+					m.apply("due", func(tasks []todo.Task, i int) []todo.Task {
+						tasks[i].DueDate, tasks[i].DueTime, tasks[i].Duration = due.DueDate, due.DueTime, due.Duration
+						return tasks
+					})
 				}
 			}
 
 		case "e":
+			if len(m.tasks) == 0 {
+				break
+			}
 			newText, ok := prompt("✏️ Edit task text:")
 			if ok && strings.TrimSpace(newText) != "" {
-				m.tasks[m.cursor].Text = strings.TrimSpace(newText)
-				_ = todo.SaveTasks(m.tasks)
+				m.apply("edit", func(tasks []todo.Task, i int) []todo.Task {
+					tasks[i].Text = strings.TrimSpace(newText)
+					return tasks
+				})
+			}
+
+		case "u":
+			summary, err := historyFor(dataFile).Undo(m.store)
+			if err != nil {
+				m.status = color.RedString("❌ %v", err)
+			} else {
+				m.status = "↩️  Undid " + summary
 			}
+			m.reload()
+
+		case "ctrl+r":
+			summary, err := historyFor(dataFile).Redo(m.store)
+			if err != nil {
+				m.status = color.RedString("❌ %v", err)
+			} else {
+				m.status = "↪️  Redid " + summary
+			}
+			m.reload()
 
 		case "n":
 			newTask, ok := prompt("➕ New task:")
 			if ok && strings.TrimSpace(newTask) != "" {
-				task := todo.Task{
-					ID:   nextID(m.tasks),
-					Text: strings.TrimSpace(newTask),
+				if err := todo.AddTaskWithDueDate(m.store, strings.TrimSpace(newTask), ""); err != nil {
+					m.status = color.RedString("❌ %v", err)
 				}
-				m.tasks = append(m.tasks, task)
-				_ = todo.SaveTasks(m.tasks)
+				m.reload()
 			}
 		}
 	}
 	return m, nil
 }
 
+// apply changes the task under the cursor as it was shown. If it was
+// edited or deleted elsewhere in the meantime, the conflict is flagged and
+// the other change is kept.
+func (m *model) apply(op string, fn func(tasks []todo.Task, i int) []todo.Task) {
+	seen := m.tasks[m.cursor]
+	err := todo.ModifySeen(m.store, op, seen, fn)
+	switch {
+	case errors.Is(err, todo.ErrConflict):
+		m.status = color.YellowString("⚠️  \"%s\" was changed elsewhere; not overwriting it. Check it and try again.", seen.Text)
+	case errors.Is(err, todo.ErrTaskNotFound):
+		m.status = color.YellowString("⚠️  \"%s\" was deleted elsewhere.", seen.Text)
+	case err != nil:
+		m.status = color.RedString("❌ %v", err)
+	}
+	m.reload()
+}
+
+// reload picks up the stored list, keeping the cursor on the same task
+func (m *model) reload() {
+	stamp := fileStamp(dataFile)
+	tasks, err := m.store.Load()
+	if err != nil {
+		m.status = color.RedString("❌ %v", err)
+		return
+	}
+	current := ""
+	if m.cursor < len(m.tasks) {
+		current = m.tasks[m.cursor].UUID
+	}
+	m.tasks, m.stamp = tasks, stamp
+	for i, t := range m.tasks {
+		if current != "" && t.UUID == current {
+			m.cursor = i
+			return
+		}
+	}
+	if m.cursor >= len(m.tasks) && m.cursor > 0 {
+		m.cursor = len(m.tasks) - 1
+	}
+}
+
 
 func (m model) View() string {
 	if m.quitting {
@@ -107,7 +228,7 @@ func (m model) View() string {
 		label := task.Text
 
 		if task.DueDate != "" {
-			label += color.YellowString(" 📅 %s", task.DueDate)
+			label += color.YellowString(" 📅 %s", task.DueLabel())
 		}
 		if len(task.Tags) > 0 {
 			label += " 🏷️ " + strings.Join(task.Tags, ", ")
@@ -120,7 +241,10 @@ func (m model) View() string {
 
 		b.WriteString(fmt.Sprintf("%s %s %s\n", cursor, status, label))
 	}
-	b.WriteString("\n↑/↓ or j/k to navigate, [n] new task, [enter] toggle complete, [q] quit\n")
+	if m.status != "" {
+		b.WriteString("\n" + m.status + "\n")
+	}
+	b.WriteString("\n↑/↓ or j/k to navigate, [n] new task, [enter] toggle complete, [u] undo, [ctrl+r] redo, [q] quit\n")
 	return b.String()
 }
 
@@ -171,24 +295,16 @@ func prompt(promptText string) (string, bool) {
 	return strings.TrimSpace(final.value), final.confirm && !final.cancel
 }
 
-func StartTUI() {
-	tasks, err := todo.LoadTasks()
+func StartTUI(store todo.Store) {
+	stamp := fileStamp(dataFile)
+	tasks, err := store.Load()
 	if err != nil {
 		fmt.Println("Failed to load tasks:", err)
 		os.Exit(1)
 	}
-	p := tea.NewProgram(model{tasks: tasks})
+	p := tea.NewProgram(model{store: store, tasks: tasks, stamp: stamp})
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error running TUI:", err)
 		os.Exit(1)
 	}
-}
-func nextID(tasks []todo.Task) int {
-	max := 0
-	for _, t := range tasks {
-		if t.ID > max {
-			max = t.ID
-		}
-	}
-	return max + 1
 }
\ No newline at end of file
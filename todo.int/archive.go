@@ -0,0 +1,119 @@
+package todo
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Archive keeps completed tasks that were moved out of the active list. It
+// is a task file of its own, next to the task file, so it can also be
+// opened directly with --file.
+type Archive struct {
+	Path       string
+	Encryption *Encryption
+}
+
+// ArchiveFor returns the archive that belongs to the task file at dataPath
+func ArchiveFor(dataPath string) *Archive {
+	return &Archive{Path: SidecarPath(dataPath, "archive.json")}
+}
+
+// Record adds tasks removed by an archive operation to the archive. Tasks
+// that reappear in the list (through undo, say) are taken back out of it.
+func (a *Archive) Record(c Change) error {
+	var archived []Task
+	back := map[string]bool{}
+	for _, d := range c.Diffs() {
+		switch {
+		case d.After == nil && c.Op == "archive":
+			archived = append(archived, *d.Before)
+		case d.Before == nil:
+			back[d.After.UUID] = true
+		}
+	}
+	if len(archived) == 0 && len(back) == 0 {
+		return nil
+	}
+	// Nothing to take back from an archive that doesn't exist yet
+	if len(archived) == 0 {
+		if _, err := os.Stat(a.Path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+
+	tasks, err := a.Tasks()
+	if err != nil {
+		return err
+	}
+	kept := tasks[:0]
+	for _, t := range tasks {
+		if !back[t.UUID] {
+			kept = append(kept, t)
+		}
+	}
+	return a.save(append(kept, archived...))
+}
+
+// Tasks returns everything in the archive, oldest archived first
+func (a *Archive) Tasks() ([]Task, error) {
+	data, err := readSealed(a.Path, a.Encryption)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Task{}, nil
+		}
+		return nil, err
+	}
+	tasks, _, err := decodeTaskFile(data)
+	return tasks, err
+}
+
+func (a *Archive) save(tasks []Task) error {
+	data, err := encodeTaskFile(tasks)
+	if err != nil {
+		return err
+	}
+	return writeSealed(a.Path, data, a.Encryption)
+}
+
+// ArchiveTasks moves tasks completed before cutoff out of the list. Tasks
+// completed before completion times were recorded are left where they are,
+// since there's no telling how old they are. When the store is tracked
+// with an Archive they end up there. Returns how many tasks were archived.
+func ArchiveTasks(s Store, cutoff time.Time) (int, error) {
+	old := func(t Task) bool {
+		at, ok := t.CompletedTime()
+		return ok && at.Before(cutoff)
+	}
+
+	// Check first, so a run with nothing to do doesn't count as a change
+	tasks, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+	found := false
+	for _, t := range tasks {
+		if old(t) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, nil
+	}
+
+	archived := 0
+	err = Modify(s, "archive", func(tasks []Task) ([]Task, error) {
+		archived = 0
+		kept := tasks[:0]
+		for _, t := range tasks {
+			if old(t) {
+				archived++
+				continue
+			}
+			kept = append(kept, t)
+		}
+		return kept, nil
+	})
+	return archived, err
+}
@@ -0,0 +1,198 @@
+package todo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBackupKeep is how many backups are kept per task file
+const DefaultBackupKeep = 20
+
+const backupStamp = "20060102-150405"
+
+// Backups snapshots the task list into a backups directory next to the
+// task file: before every destructive operation, and otherwise at most
+// once a day. Only the newest Keep snapshots are kept.
+type Backups struct {
+	Dir        string
+	Name       string // task file name, e.g. "tasks.json"
+	Keep       int
+	Encryption *Encryption
+}
+
+// Backup is one snapshot on disk
+type Backup struct {
+	Name string
+	Path string
+	Time time.Time
+	Op   string
+}
+
+// BackupsFor returns the backups that belong to the task file at dataPath
+func BackupsFor(dataPath string) *Backups {
+	return &Backups{
+		Dir:  filepath.Join(filepath.Dir(dataPath), "backups"),
+		Name: filepath.Base(dataPath),
+		Keep: DefaultBackupKeep,
+	}
+}
+
+// destructive reports whether a change throws work away and so always
+// deserves a snapshot of what came before it
+func destructive(c Change) bool {
+	switch c.Op {
+	case "clear", "reset", "rebuild", "backup-restore":
+		return true
+	case "delete":
+		removed := 0
+		for _, d := range c.Diffs() {
+			if d.After == nil {
+				removed++
+			}
+		}
+		return removed > 1
+	}
+	return false
+}
+
+// Record snapshots the list as it was before c when c is destructive, or
+// when there is no snapshot from today yet
+func (b *Backups) Record(c Change) error {
+	if len(c.Before) == 0 {
+		return nil
+	}
+	if !destructive(c) {
+		latest, err := b.List()
+		if err != nil {
+			return err
+		}
+		if len(latest) > 0 && sameDay(latest[0].Time, c.Time) {
+			return nil
+		}
+		return b.snapshot(c.Before, c.Time, "daily")
+	}
+	return b.snapshot(c.Before, c.Time, c.Op)
+}
+
+// Snapshot saves tasks as a backup right now, tagged with op
+func (b *Backups) Snapshot(tasks []Task, op string) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	return b.snapshot(tasks, time.Now(), op)
+}
+
+func (b *Backups) snapshot(tasks []Task, at time.Time, op string) error {
+	if err := os.MkdirAll(b.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := encodeTaskFile(tasks)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%s.json", b.Name, at.Format(backupStamp), op)
+	if err := writeSealed(filepath.Join(b.Dir, name), data, b.Encryption); err != nil {
+		return err
+	}
+	return b.rotate()
+}
+
+// List returns the backups for this task file, newest first
+func (b *Backups) List() ([]Backup, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Backup
+	prefix := b.Name + "-"
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || filepath.Ext(name) != ".json" {
+			continue
+		}
+		rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json")
+		if len(rest) < len(backupStamp)+2 {
+			continue
+		}
+		at, err := time.ParseInLocation(backupStamp, rest[:len(backupStamp)], time.Local)
+		if err != nil {
+			continue
+		}
+		out = append(out, Backup{Name: name, Path: filepath.Join(b.Dir, name), Time: at, Op: rest[len(backupStamp)+1:]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name > out[j].Name })
+	return out, nil
+}
+
+// Find looks a backup up by name (or unique name prefix)
+func (b *Backups) Find(name string) (Backup, error) {
+	all, err := b.List()
+	if err != nil {
+		return Backup{}, err
+	}
+	var match []Backup
+	for _, bk := range all {
+		if bk.Name == name {
+			return bk, nil
+		}
+		if strings.HasPrefix(bk.Name, name) {
+			match = append(match, bk)
+		}
+	}
+	switch len(match) {
+	case 0:
+		return Backup{}, fmt.Errorf("no backup named %s", name)
+	case 1:
+		return match[0], nil
+	default:
+		return Backup{}, fmt.Errorf("%q matches %d backups", name, len(match))
+	}
+}
+
+// Load reads the tasks saved in a backup
+func (b *Backups) Load(bk Backup) ([]Task, error) {
+	data, err := readSealed(bk.Path, b.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	tasks, _, err := decodeTaskFile(data)
+	return tasks, err
+}
+
+// Restore replaces the current list with the backup's contents. The list
+// being replaced is itself backed up first.
+func (b *Backups) Restore(s Store, bk Backup) error {
+	tasks, err := b.Load(bk)
+	if err != nil {
+		return err
+	}
+	return Modify(s, "backup-restore", func([]Task) ([]Task, error) {
+		return tasks, nil
+	})
+}
+
+func (b *Backups) rotate() error {
+	all, err := b.List()
+	if err != nil || b.Keep <= 0 || len(all) <= b.Keep {
+		return err
+	}
+	for _, bk := range all[b.Keep:] {
+		if err := os.Remove(bk.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Local().Date()
+	by, bm, bd := b.Local().Date()
+	return ay == by && am == bm && ad == bd
+}
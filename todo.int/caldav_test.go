@@ -0,0 +1,291 @@
+package todo
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCalDAV is a CalDAV stand-in for one collection at /cal/: GET,
+// conditional PUT and DELETE on its resources and the sync-collection
+// REPORT, with a log of changes to answer sync tokens from
+type fakeCalDAV struct {
+	mu      sync.Mutex
+	items   map[string]davFile // by path
+	log     []string           // paths in the order they changed
+	forget  int                // status refusing every sync token, once set
+	gets    []string           // paths downloaded, in order
+	reports []string           // sync tokens sent
+}
+
+func newFakeCalDAV() *fakeCalDAV {
+	return &fakeCalDAV{items: map[string]davFile{}}
+}
+
+// write stores a task as the phone would. A nil task deletes it.
+func (d *fakeCalDAV) write(name string, t *Task) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := "/cal/" + name
+	if t == nil {
+		delete(d.items, p)
+	} else {
+		d.put(p, EncodeCalendar([]Task{*t}))
+	}
+	d.log = append(d.log, p)
+}
+
+func (d *fakeCalDAV) put(p string, data []byte) {
+	d.items[p] = davFile{data: data, etag: fmt.Sprintf(`"e%d"`, len(d.log)+1)}
+}
+
+const syncTokenPrefix = "http://example.com/sync/"
+
+// syncToken names the collection's state after its first n changes
+func syncToken(n int) string { return syncTokenPrefix + strconv.Itoa(n) }
+
+func (d *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, exists := d.items[r.URL.Path]
+	switch r.Method {
+	case "REPORT":
+		d.report(w, r)
+	case http.MethodGet:
+		d.gets = append(d.gets, r.URL.Path)
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", f.etag)
+		w.Write(f.data)
+	case http.MethodPut, http.MethodDelete:
+		ifMatch, ifNone := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		if ifNone == "*" && exists || ifMatch != "" && (!exists || ifMatch != f.etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(d.items, r.URL.Path)
+			d.log = append(d.log, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		d.put(r.URL.Path, data)
+		d.log = append(d.log, r.URL.Path)
+		w.Header().Set("ETag", d.items[r.URL.Path].etag)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// report answers a sync-collection REPORT with the resources changed
+// since the token sent, or all of them without one
+func (d *fakeCalDAV) report(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `xml:"sync-token"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	d.reports = append(d.reports, req.Token)
+
+	var paths []string
+	if req.Token == "" {
+		for p := range d.items {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+	} else {
+		n, err := strconv.Atoi(strings.TrimPrefix(req.Token, syncTokenPrefix))
+		if err != nil || n > len(d.log) || d.forget != 0 {
+			status := d.forget
+			if status == 0 {
+				status = http.StatusForbidden
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, `<d:error xmlns:d="DAV:"><d:valid-sync-token/></d:error>`)
+			return
+		}
+		seen := map[string]bool{}
+		for _, p := range d.log[n:] {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<d:multistatus xmlns:d="DAV:">`)
+	for _, p := range paths {
+		if f, ok := d.items[p]; ok {
+			fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, p, strings.ReplaceAll(f.etag, `"`, "&quot;"))
+		} else {
+			fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, p)
+		}
+	}
+	fmt.Fprintf(&b, `<d:sync-token>%s</d:sync-token></d:multistatus>`, syncToken(len(d.log)))
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, b.String())
+}
+
+// downloaded returns the paths fetched since it was last called
+func (d *fakeCalDAV) downloaded() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	gets := d.gets
+	d.gets = nil
+	sort.Strings(gets)
+	return gets
+}
+
+func newTestCalDAVStore(t *testing.T, d *fakeCalDAV) *CalDAVStore {
+	t.Helper()
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	s, err := NewCalDAVStore(strings.Replace(srv.URL, "http://", "caldav+http://", 1)+"/cal/", filepath.Join(t.TempDir(), "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// seedCalDAV puts two to-dos in the collection, as made on a phone
+func seedCalDAV(d *fakeCalDAV) {
+	d.write("milk.ics", &Task{UUID: "milk", Text: "Buy milk"})
+	d.write("mom.ics", &Task{UUID: "mom", Text: "Call mom", Tags: []string{"family"}})
+}
+
+func TestCalDAVStoreSyncsIncrementally(t *testing.T) {
+	d := newFakeCalDAV()
+	seedCalDAV(d)
+	s := newTestCalDAVStore(t, d)
+
+	if got := taskTexts(t, s); !reflect.DeepEqual(got, []string{"Buy milk", "Call mom"}) {
+		t.Fatalf("first load = %q", got)
+	}
+	if got := d.downloaded(); !reflect.DeepEqual(got, []string{"/cal/milk.ics", "/cal/mom.ics"}) {
+		t.Errorf("first load downloaded %q, want both", got)
+	}
+
+	// The phone edits one, deletes the other and adds a third
+	d.write("milk.ics", &Task{UUID: "milk", Text: "Buy oat milk"})
+	d.write("mom.ics", nil)
+	d.write("rent.ics", &Task{UUID: "rent", Text: "Pay rent"})
+
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.reports[len(d.reports)-1] != syncToken(2) {
+		t.Errorf("second REPORT sent token %q, want %q", d.reports[len(d.reports)-1], syncToken(2))
+	}
+	if got := d.downloaded(); !reflect.DeepEqual(got, []string{"/cal/milk.ics", "/cal/rent.ics"}) {
+		t.Errorf("incremental load downloaded %q, want only the changed ones", got)
+	}
+	if len(tasks) != 2 || tasks[0].ID != 1 || tasks[0].Text != "Buy oat milk" || tasks[1].ID != 2 || tasks[1].Text != "Pay rent" {
+		t.Errorf("tasks = %+v, want 1 Buy oat milk and 2 Pay rent", tasks)
+	}
+
+	// Nothing changed: nothing downloaded
+	if _, err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.downloaded(); len(got) != 0 {
+		t.Errorf("load without changes downloaded %q", got)
+	}
+
+	// A local change uploads just that task; the next load doesn't fetch
+	// it back
+	if err := MarkTaskDone(s, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.downloaded(); len(got) != 0 {
+		t.Errorf("load after our own write downloaded %q", got)
+	}
+	phone, err := DecodeCalendar(d.items["/cal/rent.ics"].data)
+	if err != nil || len(phone) != 1 || !phone[0].Completed {
+		t.Errorf("the phone sees %+v, %v; want rent done", phone, err)
+	}
+}
+
+func TestCalDAVStoreRefusesStalePut(t *testing.T) {
+	d := newFakeCalDAV()
+	seedCalDAV(d)
+	s := newTestCalDAVStore(t, d)
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.write("milk.ics", &Task{UUID: "milk", Text: "Buy oat milk"})
+	tasks[0].Text = "Buy soy milk"
+	if err := s.Save(tasks); !errors.Is(err, ErrRemoteChanged) {
+		t.Fatalf("Save over the phone's edit = %v, want ErrRemoteChanged", err)
+	}
+	if phone, _ := DecodeCalendar(d.items["/cal/milk.ics"].data); phone[0].Text != "Buy oat milk" {
+		t.Errorf("the phone's edit was overwritten with %q", phone[0].Text)
+	}
+
+	// Loading again picks up the phone's edit, after which saving works
+	tasks, err = s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[0].Text != "Buy oat milk" {
+		t.Errorf("reload = %q, want the phone's edit", tasks[0].Text)
+	}
+	tasks[0].Text = "Buy soy milk"
+	if err := s.Save(tasks); err != nil {
+		t.Fatalf("Save after reloading: %v", err)
+	}
+}
+
+func TestCalDAVStoreResyncsWhenTokenIsRefused(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusConflict} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			d := newFakeCalDAV()
+			seedCalDAV(d)
+			s := newTestCalDAVStore(t, d)
+			if _, err := s.Load(); err != nil {
+				t.Fatal(err)
+			}
+			d.downloaded()
+
+			d.forget = status
+			d.write("milk.ics", &Task{UUID: "milk", Text: "Buy oat milk"})
+			tasks, err := s.Load()
+			if err != nil {
+				t.Fatalf("Load with a refused token: %v", err)
+			}
+			if n := len(d.reports); n < 2 || d.reports[n-2] != syncToken(2) || d.reports[n-1] != "" {
+				t.Errorf("REPORTs sent tokens %q, want the old one then none", d.reports)
+			}
+			// The full listing only downloads what changed
+			if got := d.downloaded(); !reflect.DeepEqual(got, []string{"/cal/milk.ics"}) {
+				t.Errorf("resync downloaded %q, want only milk.ics", got)
+			}
+			if len(tasks) != 2 || tasks[0].ID != 1 || tasks[0].Text != "Buy oat milk" || tasks[1].ID != 2 {
+				t.Errorf("tasks = %+v, want IDs kept", tasks)
+			}
+		})
+	}
+}
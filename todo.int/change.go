@@ -0,0 +1,127 @@
+package todo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Change describes one mutation made through Modify
+type Change struct {
+	Op     string    `json:"op"`
+	Time   time.Time `json:"time"`
+	Before []Task    `json:"-"`
+	After  []Task    `json:"-"`
+}
+
+// TaskDiff is what happened to a single task in a Change. Before is nil for
+// an added task and After is nil for a removed one. Index is the task's
+// position in the list it was taken from.
+type TaskDiff struct {
+	Before *Task `json:"before,omitempty"`
+	After  *Task `json:"after,omitempty"`
+	Index  int   `json:"index"`
+}
+
+// Diffs lists the tasks that were added, removed or edited, matched by UUID
+func (c Change) Diffs() []TaskDiff {
+	return DiffTasks(c.Before, c.After)
+}
+
+// String summarises the change, e.g. "done: 4 Write documentation"
+func (c Change) String() string {
+	var names []string
+	for _, d := range c.Diffs() {
+		t := d.After
+		if t == nil {
+			t = d.Before
+		}
+		names = append(names, fmt.Sprintf("%d %s", t.ID, t.Text))
+	}
+	if len(names) == 0 {
+		return c.Op
+	}
+	if len(names) > 3 {
+		names = append(names[:3], fmt.Sprintf("and %d more", len(names)-3))
+	}
+	return c.Op + ": " + strings.Join(names, ", ")
+}
+
+// DiffTasks matches tasks by UUID and lists the ones that were added,
+// removed or changed going from before to after
+func DiffTasks(before, after []Task) []TaskDiff {
+	var diffs []TaskDiff
+	old := map[string]int{}
+	for i, t := range before {
+		old[t.UUID] = i
+	}
+	seen := map[string]bool{}
+	for i := range after {
+		a := after[i]
+		seen[a.UUID] = true
+		j, ok := old[a.UUID]
+		if !ok {
+			diffs = append(diffs, TaskDiff{After: &a, Index: i})
+			continue
+		}
+		b := before[j]
+		if !reflect.DeepEqual(b, a) {
+			diffs = append(diffs, TaskDiff{Before: &b, After: &a, Index: i})
+		}
+	}
+	for i := range before {
+		b := before[i]
+		if !seen[b.UUID] {
+			diffs = append(diffs, TaskDiff{Before: &b, Index: i})
+		}
+	}
+	return diffs
+}
+
+// Recorder is told about every change made through Modify, after it has
+// been saved and while the store lock is still held
+type Recorder interface {
+	Record(c Change) error
+}
+
+// TrackedStore wraps a Store and passes each change to its recorders
+// (undo history and the like). Locking and native queries are forwarded
+// to the wrapped store.
+type TrackedStore struct {
+	Store
+	recorders []Recorder
+}
+
+// Track wraps s so changes made through Modify reach every recorder
+func Track(s Store, recorders ...Recorder) *TrackedStore {
+	return &TrackedStore{Store: s, recorders: recorders}
+}
+
+// Record hands c to each recorder in turn
+func (t *TrackedStore) Record(c Change) error {
+	for _, r := range t.recorders {
+		if err := r.Record(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock forwards to the wrapped store when it supports locking
+func (t *TrackedStore) Lock() (func() error, error) {
+	if l, ok := t.Store.(Locker); ok {
+		return l.Lock()
+	}
+	return func() error { return nil }, nil
+}
+
+// Query forwards to the wrapped store so native filtering still applies
+func (t *TrackedStore) Query(options ListFilterOptions) ([]Task, error) {
+	return QueryTasks(t.Store, options)
+}
+
+// Unwrap returns the wrapped store
+func (t *TrackedStore) Unwrap() Store {
+	return t.Store
+}
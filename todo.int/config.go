@@ -0,0 +1,108 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFileName is the task file used when nothing else is configured
+const DefaultFileName = "tasks.json"
+
+// Config holds user settings read from $XDG_CONFIG_HOME/todo/config.json
+type Config struct {
+	File string `json:"file,omitempty"`
+	// Git commits the task file to a git repository in its directory
+	// after every change
+	Git bool `json:"git,omitempty"`
+	// List is the list used when --list isn't given
+	List string `json:"list,omitempty"`
+	// ArchiveAfterDays archives tasks completed more than this many days
+	// ago on every run; 0 turns auto-archiving off
+	ArchiveAfterDays int `json:"archive_after_days,omitempty"`
+}
+
+// ConfigPath returns where the config file lives
+func ConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", "config.json"), nil
+}
+
+// LoadConfig reads the config file. A missing file is an empty config.
+func LoadConfig() (Config, error) {
+	var cfg Config
+	path, err := ConfigPath()
+	if err != nil {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// ResolveDataFile works out which task file to use, in order of priority:
+// the --file flag, $TODO_FILE, the config file, then
+// $XDG_DATA_HOME/todo/tasks.json (~/.local/share/todo/tasks.json).
+// The parent directory is created if it doesn't exist yet.
+func ResolveDataFile(flagValue string) (string, error) {
+	path := flagValue
+	if path == "" {
+		path = os.Getenv("TODO_FILE")
+	}
+	if path == "" {
+		cfg, err := LoadConfig()
+		if err != nil {
+			return "", err
+		}
+		path = cfg.File
+	}
+	if path == "" {
+		dir, err := dataHome()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, "todo", DefaultFileName)
+	}
+
+	path, err := expandHome(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func dataHome() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
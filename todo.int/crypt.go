@@ -0,0 +1,364 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// sealedScheme identifies the encryption used for a sealed file
+const sealedScheme = "aes-256-gcm+argon2id"
+
+// KeyCacheTTL is how long a derived key stays cached for the session
+const KeyCacheTTL = 8 * time.Hour
+
+// ErrEncrypted is returned when reading an encrypted file without a key
+var ErrEncrypted = errors.New("task file is encrypted; set TODO_PASSPHRASE or run from a terminal to enter the passphrase")
+
+// sealedFile is the on-disk envelope of an encrypted file (or journal line)
+type sealedFile struct {
+	Sealed string `json:"sealed"`
+	Salt   []byte `json:"salt"`
+	Nonce  []byte `json:"nonce"`
+	Data   []byte `json:"data"`
+}
+
+// Encryption seals task data with AES-256-GCM under a key derived from a
+// passphrase with Argon2id. Derived keys are cached in a per-user runtime
+// directory for KeyCacheTTL, so the passphrase is asked once per session.
+// A nil *Encryption leaves data in plain text.
+type Encryption struct {
+	// Passphrase is asked for a passphrase the first time a key is needed
+	Passphrase func() (string, error)
+	// CacheDir holds cached keys; empty disables the cache
+	CacheDir string
+
+	salt []byte
+	keys map[string][]byte
+	pass *string
+}
+
+// NewEncryption returns an Encryption that gets its passphrase from
+// passphrase and caches derived keys in the default session directory
+func NewEncryption(passphrase func() (string, error)) *Encryption {
+	return &Encryption{Passphrase: passphrase, CacheDir: KeyCacheDir(), keys: map[string][]byte{}}
+}
+
+// KeyCacheDir is $XDG_RUNTIME_DIR/todo, which is private to the user and
+// cleared at logout, or a per-user temp directory when that isn't set
+func KeyCacheDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "todo")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("todo-%d", os.Getuid()))
+}
+
+// IsSealedFile reports whether the file at path is encrypted
+func IsSealedFile(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && isSealed(data)
+}
+
+// IsEncrypted reports whether the task file at dataPath, or the files kept
+// next to it, are encrypted. The sidecars are checked too so a reset task
+// file doesn't silently switch the rest back to plain text.
+func IsEncrypted(dataPath string) bool {
+	return IsSealedFile(dataPath) || IsSealedFile(HistoryFor(dataPath).Path) || IsSealedFile(TrashFor(dataPath).Path)
+}
+
+func isSealed(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+	var probe struct {
+		Sealed string `json:"sealed"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Sealed != ""
+}
+
+// seal encrypts data into a single-line envelope. A nil receiver returns
+// data unchanged.
+func (e *Encryption) seal(data []byte) ([]byte, error) {
+	if e == nil {
+		return data, nil
+	}
+	if e.salt == nil {
+		e.salt = make([]byte, 16)
+		if _, err := rand.Read(e.salt); err != nil {
+			return nil, err
+		}
+	}
+	gcm, err := e.cipher(e.salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedFile{
+		Sealed: sealedScheme,
+		Salt:   e.salt,
+		Nonce:  nonce,
+		Data:   gcm.Seal(nil, nonce, data, []byte(sealedScheme)),
+	})
+}
+
+// open decrypts an envelope, or returns data unchanged if it isn't one
+func (e *Encryption) open(data []byte) ([]byte, error) {
+	if !isSealed(data) {
+		return data, nil
+	}
+	if e == nil {
+		return nil, ErrEncrypted
+	}
+	var f sealedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Sealed != sealedScheme {
+		return nil, fmt.Errorf("unsupported encryption %q", f.Sealed)
+	}
+	gcm, err := e.cipher(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, []byte(sealedScheme))
+	if err != nil {
+		e.forget(f.Salt)
+		return nil, errors.New("wrong passphrase or corrupted file")
+	}
+	if e.salt == nil {
+		e.salt = f.Salt
+	}
+	return plain, nil
+}
+
+func (e *Encryption) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := e.key(salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// key returns the key for salt, from memory, the session cache, or by
+// asking for the passphrase and deriving it
+func (e *Encryption) key(salt []byte) ([]byte, error) {
+	if e.keys == nil {
+		e.keys = map[string][]byte{}
+	}
+	id := hex.EncodeToString(salt)
+	if k, ok := e.keys[id]; ok {
+		return k, nil
+	}
+	if k := e.cachedKey(salt); k != nil {
+		e.keys[id] = k
+		return k, nil
+	}
+
+	if e.pass == nil {
+		if e.Passphrase == nil {
+			return nil, ErrEncrypted
+		}
+		p, err := e.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if p == "" {
+			return nil, errors.New("empty passphrase")
+		}
+		e.pass = &p
+	}
+	k := argon2.IDKey([]byte(*e.pass), salt, 1, 64*1024, 4, 32)
+	e.keys[id] = k
+	e.cacheKey(salt, k)
+	return k, nil
+}
+
+func (e *Encryption) cacheFile(salt []byte) string {
+	sum := sha256.Sum256(salt)
+	return filepath.Join(e.CacheDir, hex.EncodeToString(sum[:8])+".key")
+}
+
+func (e *Encryption) cachedKey(salt []byte) []byte {
+	if e.CacheDir == "" || privateDir(e.CacheDir) != nil {
+		return nil
+	}
+	f, err := os.OpenFile(e.cacheFile(salt), os.O_RDONLY|noFollow, 0)
+	if err != nil {
+		return nil
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil
+	}
+	parts := strings.Fields(string(data))
+	if len(parts) != 2 {
+		return nil
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		os.Remove(e.cacheFile(salt))
+		return nil
+	}
+	k, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return nil
+	}
+	return k
+}
+
+// cacheKey saves a key for KeyCacheTTL. Keys are only written into a
+// directory privateDir accepts, to a fresh file that isn't a symlink; if
+// that can't be had the key just isn't cached.
+func (e *Encryption) cacheKey(salt, key []byte) {
+	if e.CacheDir == "" || privateDir(e.CacheDir) != nil {
+		return
+	}
+	path := e.cacheFile(salt)
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|noFollow, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s %d\n", hex.EncodeToString(key), time.Now().Add(KeyCacheTTL).Unix())
+}
+
+func (e *Encryption) forget(salt []byte) {
+	delete(e.keys, hex.EncodeToString(salt))
+	e.pass = nil
+	if e.CacheDir != "" {
+		os.Remove(e.cacheFile(salt))
+	}
+}
+
+// ForgetKeys drops every cached key, so the passphrase is asked again
+func ForgetKeys() error {
+	entries, err := os.ReadDir(KeyCacheDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".key" {
+			if err := os.Remove(filepath.Join(KeyCacheDir(), entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readSealed reads a whole file, decrypting it if needed
+func readSealed(path string, e *Encryption) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return e.open(data)
+}
+
+// writeSealed atomically writes a whole file, encrypting it if e is set
+func writeSealed(path string, data []byte, e *Encryption) error {
+	data, err := e.seal(data)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0644)
+	if e != nil {
+		perm = 0600
+	}
+	return writeFileAtomic(path, data, perm)
+}
+
+// ConvertDataFiles re-writes the task file at dataPath and everything kept
+// next to it (history, trash, archive, journal, backups) from one encryption to
+// another. Pass nil for from to encrypt plain files, nil for to to decrypt.
+func ConvertDataFiles(dataPath string, from, to *Encryption) error {
+	whole := []string{
+		dataPath,
+		HistoryFor(dataPath).Path,
+		TrashFor(dataPath).Path,
+		ArchiveFor(dataPath).Path,
+		SyncStateFor(dataPath).Path,
+		SidecarPath(dataPath, "caldav.json"),
+	}
+	backups := BackupsFor(dataPath)
+	if all, err := backups.List(); err == nil {
+		for _, bk := range all {
+			whole = append(whole, bk.Path)
+		}
+	}
+
+	for _, path := range whole {
+		data, err := readSealed(path, from)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := writeSealed(path, data, to); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	journal := JournalFor(dataPath).Path
+	data, err := os.ReadFile(journal)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		line, err := from.open(sc.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s: %w", journal, err)
+		}
+		if line, err = to.seal(line); err != nil {
+			return err
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	perm := os.FileMode(0644)
+	if to != nil {
+		perm = 0600
+	}
+	return writeFileAtomic(journal, out.Bytes(), perm)
+}
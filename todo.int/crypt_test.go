@@ -0,0 +1,170 @@
+package todo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEncryption returns an Encryption with the given passphrase and a
+// key cache of its own. asked counts the times the passphrase was asked.
+func testEncryption(passphrase, cacheDir string) (e *Encryption, asked *int) {
+	asked = new(int)
+	return &Encryption{
+		Passphrase: func() (string, error) {
+			*asked++
+			return passphrase, nil
+		},
+		CacheDir: cacheDir,
+	}, asked
+}
+
+func TestEncryptedJSONStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	cache := filepath.Join(t.TempDir(), "keys")
+	s := NewJSONStore(path)
+	s.Encryption, _ = testEncryption("correct horse", cache)
+	for _, text := range []string{"Buy milk", "Call mom"} {
+		if err := AddTaskWithDueDate(s, text, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealedFile(path) || strings.Contains(string(data), "milk") {
+		t.Fatalf("file isn't encrypted:\n%s", data)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode %o, want 0600", fi.Mode().Perm())
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string // "" for no Encryption at all
+		wantErr    string
+	}{
+		{name: "right passphrase", passphrase: "correct horse"},
+		{name: "wrong passphrase", passphrase: "battery staple", wantErr: "wrong passphrase"},
+		{name: "no key", wantErr: ErrEncrypted.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewJSONStore(path)
+			if tt.passphrase != "" {
+				// A fresh cache, so the key is derived from the passphrase
+				r.Encryption, _ = testEncryption(tt.passphrase, filepath.Join(t.TempDir(), "keys"))
+			}
+			tasks, err := r.Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load = %v, %v; want %q", tasks, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := taskTexts(t, r); !reflect.DeepEqual(got, []string{"Buy milk", "Call mom"}) {
+				t.Errorf("decrypted %q", got)
+			}
+		})
+	}
+}
+
+func TestEncryptionKeyCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	cache := filepath.Join(t.TempDir(), "keys")
+	s := NewJSONStore(path)
+	s.Encryption, _ = testEncryption("correct horse", cache)
+	if err := AddTaskWithDueDate(s, "Buy milk", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process in the same session finds the key in the cache
+	again := NewJSONStore(path)
+	var asked *int
+	again.Encryption, asked = testEncryption("correct horse", cache)
+	if _, err := again.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if *asked != 0 {
+		t.Errorf("passphrase asked %d times with the key cached", *asked)
+	}
+
+	// A wrong passphrase isn't remembered: the next try asks again
+	wrong := NewJSONStore(path)
+	wrong.Encryption, asked = testEncryption("battery staple", filepath.Join(t.TempDir(), "keys"))
+	for i := 0; i < 2; i++ {
+		if _, err := wrong.Load(); err == nil {
+			t.Fatal("loaded with the wrong passphrase")
+		}
+	}
+	if *asked != 2 {
+		t.Errorf("passphrase asked %d times for two tries, want 2", *asked)
+	}
+}
+
+func TestConvertDataFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	s := Track(NewJSONStore(path), HistoryFor(path), JournalFor(path), TrashFor(path))
+	for _, text := range []string{"Buy milk", "Call mom"} {
+		if err := AddTaskWithDueDate(s, text, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := DeleteTask(s, "2"); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{path, HistoryFor(path).Path, JournalFor(path).Path, TrashFor(path).Path}
+
+	key, _ := testEncryption("correct horse", filepath.Join(t.TempDir(), "keys"))
+	if err := ConvertDataFiles(path, nil, key); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "milk") {
+			t.Errorf("%s still readable after encrypting:\n%s", filepath.Base(f), data)
+		}
+	}
+	if !IsEncrypted(path) {
+		t.Error("IsEncrypted = false after encrypting")
+	}
+
+	// Everything still reads with the key
+	j := JournalFor(path)
+	j.Encryption = key
+	events, err := j.Events()
+	if err != nil || len(events) == 0 {
+		t.Errorf("journal with the key: %d events, %v", len(events), err)
+	}
+	if _, err := JournalFor(path).Events(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("journal without the key: %v, want ErrEncrypted", err)
+	}
+
+	if err := ConvertDataFiles(path, key, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if data, _ := os.ReadFile(f); isSealed(data) || strings.Contains(string(data), `"sealed"`) {
+			t.Errorf("%s still encrypted after decrypting", filepath.Base(f))
+		}
+	}
+	plain := Track(NewJSONStore(path), TrashFor(path))
+	if got := taskTexts(t, plain); !reflect.DeepEqual(got, []string{"Buy milk"}) {
+		t.Errorf("tasks after the round trip: %q", got)
+	}
+	trashed, err := TrashFor(path).List()
+	if err != nil || len(trashed) != 1 || trashed[0].Task.Text != "Call mom" {
+		t.Errorf("trash after the round trip: %+v, %v", trashed, err)
+	}
+}
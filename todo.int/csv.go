@@ -0,0 +1,345 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CSV columns are named after the task fields (CSVFields). On import other
+// headers can be assigned to fields with a CSVMapping; those left over are
+// kept in Task.Extra under "csv" and written back out as columns of their
+// own, so a spreadsheet keeps its extra columns across a round trip.
+
+// CSVFields are the Task fields a CSV column can hold. "due" is a due date
+// with an optional time ("2026-10-20 09:30"), "id" is only used to resolve
+// parent references between rows, and "list" names the list of the row.
+var CSVFields = []string{
+	"id", "text", "completed", "due", "due_date", "due_time", "duration",
+	"tags", "priority", "recurring", "parent", "uuid", "completed_at", "list",
+}
+
+// csvColumns are the columns EncodeCSV writes, before the extra ones
+var csvColumns = []string{
+	"id", "text", "completed", "due_date", "due_time", "duration",
+	"tags", "priority", "recurring", "parent", "uuid", "completed_at",
+}
+
+// csvAliases are headers common in spreadsheets that map to a field
+// without a CSVMapping
+var csvAliases = map[string]string{
+	"title": "text", "task": "text", "name": "text", "summary": "text",
+	"done": "completed", "status": "completed",
+	"due_at": "due", "deadline": "due",
+	"tag": "tags", "labels": "tags", "label": "tags",
+	"recurrence": "recurring", "repeat": "recurring",
+}
+
+var csvClock = regexp.MustCompile(`^(\d{1,2}:\d{2})(:\d{2})?$`)
+
+// CSVMapping maps column headers to the CSVFields they hold. A header
+// mapped to "-" is skipped.
+type CSVMapping map[string]string
+
+// ParseCSVMapping reads a mapping like "Title=text,Due Date=due"
+func ParseCSVMapping(spec string) (CSVMapping, error) {
+	m := CSVMapping{}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		header, field, ok := strings.Cut(pair, "=")
+		header, field = strings.TrimSpace(header), strings.ToLower(strings.TrimSpace(field))
+		if !ok || header == "" {
+			return nil, fmt.Errorf("invalid mapping %q, want Header=field", pair)
+		}
+		if field != "-" && !isCSVField(field) {
+			return nil, fmt.Errorf("unknown field %q for %s (have %s)", field, header, strings.Join(CSVFields, ", "))
+		}
+		m[header] = field
+	}
+	return m, nil
+}
+
+func isCSVField(name string) bool {
+	for _, f := range CSVFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// field is the task field a column holds, or "" for one kept in Extra
+func (m CSVMapping) field(header string) string {
+	if f, ok := m[header]; ok {
+		return f
+	}
+	for h, f := range m {
+		if strings.EqualFold(h, header) {
+			return f
+		}
+	}
+	name := strings.Join(strings.Fields(strings.ToLower(header)), "_")
+	if isCSVField(name) {
+		return name
+	}
+	return csvAliases[name]
+}
+
+// EncodeCSV writes tasks as CSV with a header row
+func EncodeCSV(tasks []Task) ([]byte, error) {
+	return EncodeCSVLists(tasks, nil)
+}
+
+// EncodeCSVLists writes tasks as CSV, with a list column naming the list
+// each came from when lists is set (lists[i] for tasks[i])
+func EncodeCSVLists(tasks []Task, lists []string) ([]byte, error) {
+	extras := make([]map[string]string, len(tasks))
+	var extraColumns []string
+	seen := map[string]bool{}
+	for i, t := range tasks {
+		if raw, ok := t.Extra["csv"]; ok {
+			_ = json.Unmarshal(raw, &extras[i])
+		}
+		var keys []string
+		for k := range extras[i] {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		extraColumns = append(extraColumns, keys...)
+	}
+
+	header := append([]string{}, csvColumns...)
+	if lists != nil {
+		header = append(header, "list")
+	}
+	header = append(header, extraColumns...)
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for i, t := range tasks {
+		row := []string{
+			fmt.Sprint(t.ID), t.Text, fmt.Sprint(t.Completed), t.DueDate, t.DueTime, t.Duration,
+			strings.Join(t.Tags, ", "), t.Priority, t.Recurring, t.Parent, t.UUID, t.CompletedAt,
+		}
+		if lists != nil {
+			row = append(row, lists[i])
+		}
+		for _, k := range extraColumns {
+			row = append(row, extras[i][k])
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// DecodeCSV reads tasks from CSV with a header row, matching headers to
+// fields by name
+func DecodeCSV(data []byte) ([]Task, error) {
+	tasks, _, _, err := DecodeCSVMapped(data, nil)
+	return tasks, err
+}
+
+// DecodeCSVMapped reads tasks from CSV with a header row, taking the field
+// of each column from mapping, or else from its name. Dates are read in any
+// form ParseNaturalDate takes and stored as YYYY-MM-DD. lists[i] is the
+// list column of tasks[i], if there is one. A cell that can't be read
+// (a due_time of "soon", say) is left out of its task, with a warning
+// naming the row, so one bad cell doesn't stop the import.
+func DecodeCSVMapped(data []byte, mapping CSVMapping) (tasks []Task, lists []string, warnings []string, err error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // spreadsheets like a BOM
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	if line, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")) {
+		r.Comma = ';'
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	header := rows[0]
+	fields := make([]string, len(header))
+	hasText := false
+	for i, h := range header {
+		fields[i] = mapping.field(strings.TrimSpace(h))
+		hasText = hasText || fields[i] == "text"
+	}
+	if !hasText {
+		return nil, nil, nil, fmt.Errorf("no column holds the task text; map one with --map \"%s=text\"", strings.TrimSpace(header[0]))
+	}
+
+	// ids maps the id column to UUIDs, for parent references by id
+	ids := map[string]string{}
+	var parents []string
+	var rowNums []int
+	for n, row := range rows[1:] {
+		values := map[string]string{}
+		extra := map[string]string{}
+		for i, v := range row {
+			v = strings.TrimSpace(v)
+			switch {
+			case i >= len(header) || v == "" || fields[i] == "-":
+			case fields[i] == "":
+				extra[strings.TrimSpace(header[i])] = v
+			default:
+				values[fields[i]] = v
+			}
+		}
+		if values["text"] == "" {
+			continue
+		}
+		t, problems := csvTask(values)
+		for _, p := range problems {
+			warnings = append(warnings, fmt.Sprintf("row %d: %s", n+2, p))
+		}
+		if len(extra) > 0 {
+			raw, _ := json.Marshal(extra)
+			t.Extra = map[string]json.RawMessage{"csv": raw}
+		}
+		if t.UUID == "" {
+			t.UUID = newUUID()
+		}
+		if id := values["id"]; id != "" {
+			ids[values["list"]+"\x00"+id] = t.UUID
+		}
+		t.ID = len(tasks) + 1
+		tasks = append(tasks, t)
+		lists = append(lists, values["list"])
+		parents = append(parents, values["parent"])
+		rowNums = append(rowNums, n+2)
+	}
+	for i, p := range parents {
+		uuid, ok := ids[lists[i]+"\x00"+p]
+		switch {
+		case ok:
+			tasks[i].Parent = uuid
+		case p != "" && (len(p) != 36 || !isUUIDPrefix(p)):
+			// Neither a row here nor a task's UUID (from todo's own export)
+			tasks[i].Parent = ""
+			warnings = append(warnings, fmt.Sprintf("row %d: parent: no row with id %s", rowNums[i], p))
+		}
+	}
+	return tasks, lists, warnings, nil
+}
+
+// csvTask builds a task from a row's values by field. problems lists the
+// values it had to leave out.
+func csvTask(values map[string]string) (t Task, problems []string) {
+	t = Task{
+		UUID:      values["uuid"],
+		Text:      values["text"],
+		Priority:  values["priority"],
+		Recurring: values["recurring"],
+		Parent:    values["parent"],
+	}
+	if p := markdownPriority(t.Priority); p != "" {
+		t.Priority = p
+	}
+	if v := values["tags"]; v != "" {
+		sep := func(r rune) bool { return r == ',' || r == ';' }
+		if !strings.ContainsAny(v, ",;") {
+			sep = func(r rune) bool { return r == ' ' || r == '\t' }
+		}
+		for _, tag := range strings.FieldsFunc(v, sep) {
+			if tag = strings.TrimPrefix(strings.TrimSpace(tag), "#"); tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	}
+
+	for _, field := range []string{"due", "due_date"} {
+		if v := values[field]; v != "" {
+			if err := t.SetDue(csvDue(v)); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", field, err))
+			}
+		}
+	}
+	if v := values["due_time"]; v != "" {
+		m := csvClock.FindStringSubmatch(v)
+		switch {
+		case m == nil:
+			problems = append(problems, fmt.Sprintf("due_time: invalid time %q", v))
+		case t.DueDate == "":
+			problems = append(problems, fmt.Sprintf("due_time: %q without a due date", v))
+		default:
+			if err := t.SetDue(t.DueDate + " @ " + m[1]); err != nil {
+				problems = append(problems, fmt.Sprintf("due_time: %v", err))
+			}
+		}
+	}
+	if v := values["duration"]; v != "" {
+		d, err := time.ParseDuration(v)
+		switch {
+		case err != nil || d <= 0:
+			problems = append(problems, fmt.Sprintf("duration: invalid duration %q", v))
+		case t.DueTime == "":
+			problems = append(problems, fmt.Sprintf("duration: %q without a due time", v))
+		default:
+			t.Duration = shortDuration(d)
+		}
+	}
+
+	switch strings.ToLower(values["completed"]) {
+	case "true", "yes", "y", "x", "1", "done", "completed", "complete", "✓", "✔":
+		t.Completed = true
+	}
+	if v := values["completed_at"]; v != "" {
+		if at, ok := csvTime(v); ok {
+			t.Completed = true
+			t.CompletedAt = at.UTC().Format(time.RFC3339)
+		} else {
+			problems = append(problems, fmt.Sprintf("completed_at: invalid date %q", v))
+		}
+	}
+	return t, problems
+}
+
+// csvTime reads a timestamp, or a date with an optional time, as csvDue does
+func csvTime(v string) (time.Time, bool) {
+	if at, err := time.Parse(time.RFC3339, v); err == nil {
+		return at, true
+	}
+	var when Task
+	if err := when.SetDue(csvDue(v)); err != nil || when.DueDate == "" {
+		return time.Time{}, false
+	}
+	at, err := time.ParseInLocation("2006-01-02 15:04", when.DueDate+" "+when.DueTime, time.Local)
+	if err != nil {
+		at, _ = time.ParseInLocation("2006-01-02", when.DueDate, time.Local)
+	}
+	return at, true
+}
+
+// csvDue turns a spreadsheet date, maybe with a time ("2026-10-20 09:30",
+// "2026-10-20T09:30:00"), into the "date @ time" form SetDue takes
+func csvDue(v string) string {
+	if strings.Contains(v, "@") {
+		return v
+	}
+	if i := strings.LastIndexAny(v, " T"); i > 0 {
+		if m := csvClock.FindStringSubmatch(v[i+1:]); m != nil {
+			return strings.TrimSpace(v[:i]) + " @ " + m[1]
+		}
+	}
+	return v
+}
@@ -0,0 +1,135 @@
+package todo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	owner, _ := json.Marshal(map[string]string{"owner": "sam"})
+	tasks := []Task{
+		{
+			ID: 1, UUID: "aaaa0000-0000-4000-8000-000000000001", Text: "Ship release",
+			DueDate: "2026-10-20", DueTime: "09:30", Duration: "1h30m",
+			Tags: []string{"work", "release"}, Priority: "high", Recurring: "every 2 weeks",
+			Extra: map[string]json.RawMessage{"csv": owner},
+		},
+		{
+			ID: 2, UUID: "aaaa0000-0000-4000-8000-000000000002", Text: "Write notes, then post them",
+			Parent: "aaaa0000-0000-4000-8000-000000000001",
+		},
+		{
+			ID: 3, UUID: "aaaa0000-0000-4000-8000-000000000003", Text: `Say "thanks"`,
+			Completed: true, CompletedAt: "2026-10-15T18:00:00Z",
+		},
+	}
+
+	data, err := EncodeCSV(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeCSV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tasks) {
+		t.Fatalf("decoded %d tasks, want %d:\n%s", len(got), len(tasks), data)
+	}
+	for i := range tasks {
+		if !sameFields(got[i], tasks[i]) || got[i].UUID != tasks[i].UUID || got[i].ID != tasks[i].ID {
+			t.Errorf("task %d came back as\n  %+v\nwant\n  %+v", i+1, got[i], tasks[i])
+		}
+	}
+}
+
+func TestDecodeCSVMapped(t *testing.T) {
+	data := []byte("\xef\xbb\xbfKey;Title;Deadline;Parent Key;Estimate;Owner;Done\n" +
+		"1;Plan trip;2026-10-20 09:30;;3d;sam;\n" +
+		"2;Book hotel;;1;;;yes\n" +
+		";;;;;;\n")
+	mapping, err := ParseCSVMapping("Key=id,Title=text,Deadline=due,Parent Key=parent,Estimate=-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _, warnings, err := DecodeCSVMapped(data, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings: %q", warnings)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("decoded %d tasks, want 2", len(tasks))
+	}
+	if tasks[0].DueDate != "2026-10-20" || tasks[0].DueTime != "09:30" {
+		t.Errorf("due = %s, want 2026-10-20 @ 09:30", tasks[0].DueLabel())
+	}
+	if string(tasks[0].Extra["csv"]) != `{"Owner":"sam"}` {
+		t.Errorf("extra = %s, want the Owner column only", tasks[0].Extra["csv"])
+	}
+	if tasks[1].Parent != tasks[0].UUID || !tasks[1].Completed {
+		t.Errorf("second task = %+v, want a done subtask of the first", tasks[1])
+	}
+}
+
+func TestDecodeCSVBadCells(t *testing.T) {
+	data := []byte("text,due_date,due_time,duration,completed_at\n" +
+		"A,2026-10-20,soon,1h,\n" +
+		"B,2026-10-21,09:30,forever,\n" +
+		"C,,10:00,,\n" +
+		"D,2026-13-45,,,\n" +
+		"E,2026-10-22,11:00,30m,not a date\n")
+	tasks, _, warnings, err := DecodeCSVMapped(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 5 {
+		t.Fatalf("decoded %d tasks, want all 5", len(tasks))
+	}
+	wantDue := []string{"2026-10-20", "2026-10-21 09:30", "", "", "2026-10-22 11:00 for 30m"}
+	for i, want := range wantDue {
+		if got := tasks[i].DueLabel(); got != want {
+			t.Errorf("%s due = %q, want %q", tasks[i].Text, got, want)
+		}
+	}
+	if tasks[4].Completed {
+		t.Errorf("%s is completed, want the bad completed_at left out", tasks[4].Text)
+	}
+	want := []string{
+		`row 2: due_time: invalid time "soon"`,
+		`row 2: duration: "1h" without a due time`,
+		`row 3: duration: invalid duration "forever"`,
+		`row 4: due_time: "10:00" without a due date`,
+		`row 5: due_date: could not parse date: 2026-13-45`,
+		`row 6: completed_at: invalid date "not a date"`,
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %q\nwant %q", warnings, want)
+	}
+}
+
+func TestDecodeCSVUnknownParent(t *testing.T) {
+	data := []byte("id,text,parent\n" +
+		"1,Plan trip,\n" +
+		"2,Book hotel,9\n" +
+		"3,Pack,aaaa0000-0000-4000-8000-000000000001\n" +
+		"4,Buy tickets,1\n")
+	tasks, _, warnings, err := DecodeCSVMapped(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 4 {
+		t.Fatalf("decoded %d tasks, want 4", len(tasks))
+	}
+	// A UUID is kept: a filtered export can leave the parent out
+	want := []string{"", "", "aaaa0000-0000-4000-8000-000000000001", tasks[0].UUID}
+	for i, parent := range want {
+		if tasks[i].Parent != parent {
+			t.Errorf("%s parent = %q, want %q", tasks[i].Text, tasks[i].Parent, parent)
+		}
+	}
+	if w := []string{"row 3: parent: no row with id 9"}; !reflect.DeepEqual(warnings, w) {
+		t.Errorf("warnings = %q, want %q", warnings, w)
+	}
+}
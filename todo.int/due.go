@@ -22,7 +22,7 @@ func IsOverdue(date string) bool {
 //
 
 // ParseNaturalDate parses strings like:
-// "tomorrow", "in 3 days", "2024-05-20", "fri", etc.
+// "tomorrow", "in 3 days", "2024-05-20", "fri", "last fri", etc.
 func ParseNaturalDate(input string) (string, error) {
 	input = strings.ToLower(strings.TrimSpace(input))
 	now := time.Now()
@@ -32,6 +32,20 @@ func ParseNaturalDate(input string) (string, error) {
 		return f(now), nil
 	}
 
+	// Full weekday names, like the "fri" shortcuts
+	if wd, ok := weekdayNames[input]; ok {
+		return nextWeekday(wd)(now), nil
+	}
+
+	// Handle "last fri" style lookbacks
+	if strings.HasPrefix(input, "last ") {
+		wd, ok := weekdayNames[strings.TrimSpace(input[5:])]
+		if !ok {
+			return "", fmt.Errorf("unknown weekday: %s", input[5:])
+		}
+		return lastWeekday(wd)(now), nil
+	}
+
 	// Handle "in N days/weeks/months" format
 	if strings.HasPrefix(input, "in ") {
 		parts := strings.Fields(input[3:])
@@ -62,6 +76,11 @@ func ParseNaturalDate(input string) (string, error) {
 		}
 	}
 
+	// Keywords the add command understands ("tomorrow", "next week", ...)
+	if date, err := parseNaturalDate(input); err == nil {
+		return date, nil
+	}
+
 	return "", fmt.Errorf("could not parse date: %s", input)
 }
 
@@ -142,6 +161,16 @@ var abbreviationMap = map[string]func(time.Time) string{
 	},
 }
 
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
 //
 // 🧰 INTERNAL HELPERS
 //
@@ -167,6 +196,16 @@ func endOfMonth(t time.Time) string {
 	return firstOfNext.AddDate(0, 0, -1).Format("2006-01-02")
 }
 
+func lastWeekday(wd time.Weekday) func(time.Time) string {
+	return func(t time.Time) string {
+		offset := (int(t.Weekday()) - int(wd) + 7) % 7
+		if offset == 0 {
+			offset = 7
+		}
+		return t.AddDate(0, 0, -offset).Format("2006-01-02")
+	}
+}
+
 func nextWeekday(wd time.Weekday) func(time.Time) string {
 	return func(t time.Time) string {
 		offset := (int(wd) - int(t.Weekday()) + 7) % 7
@@ -0,0 +1,192 @@
+package todo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Format reads and writes tasks in another tool's file format, for todo
+// import and todo export
+type Format struct {
+	Name       string
+	Extensions []string
+	Encode     func(tasks []Task) ([]byte, error)
+	Decode     func(data []byte) ([]Task, error)
+}
+
+// Formats are the formats todo can import and export
+var Formats = []Format{
+	{
+		Name:       "json",
+		Extensions: []string{".json"},
+		Encode:     encodeTaskFile,
+		Decode: func(data []byte) ([]Task, error) {
+			tasks, _, err := decodeTaskFile(data)
+			return tasks, err
+		},
+	},
+	{
+		Name:       "todotxt",
+		Extensions: []string{".txt"},
+		Encode:     EncodeTodoTxt,
+		Decode:     DecodeTodoTxt,
+	},
+	{
+		Name:       "ics",
+		Extensions: []string{".ics", ".ical"},
+		Encode:     EncodeICS,
+		Decode:     DecodeCalendar,
+	},
+	{
+		Name:       "markdown",
+		Extensions: []string{".md", ".markdown"},
+		Encode:     EncodeMarkdown,
+		Decode:     DecodeMarkdown,
+	},
+	{
+		Name:       "csv",
+		Extensions: []string{".csv"},
+		Encode:     EncodeCSV,
+		Decode:     DecodeCSV,
+	},
+	{
+		// `task export` writes .json too, so this one needs --format
+		Name:   "taskwarrior",
+		Encode: EncodeTaskwarrior,
+		Decode: DecodeTaskwarrior,
+	},
+}
+
+// FormatNamed looks up a format by name
+func FormatNamed(name string) (Format, error) {
+	name = strings.ToLower(strings.ReplaceAll(name, ".", ""))
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown format %q (have %s)", name, strings.Join(FormatNames(), ", "))
+}
+
+// FormatForFile picks the format for a file by its extension
+func FormatForFile(path string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range Formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, nil
+			}
+		}
+	}
+	return Format{}, fmt.Errorf("can't tell the format of %s; use --format (%s)", path, strings.Join(FormatNames(), ", "))
+}
+
+// FormatNames lists the names of Formats
+func FormatNames() []string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// ImportResult sums up what an import did
+type ImportResult struct {
+	Added     int
+	Updated   int // already there by UUID, with changes
+	Unchanged int // already there, as is
+}
+
+// ImportTasks adds tasks read from another file to the list. A task whose
+// UUID is already in the list updates that task in place, and one that is
+// identical to a task already there is skipped, so importing the same file
+// twice doesn't duplicate anything. Each task in the list matches at most
+// one imported task, so repeated lines stay separate tasks. New tasks are
+// numbered after the existing ones. Subtasks should come after their
+// parents.
+func ImportTasks(s Store, imported []Task) (ImportResult, error) {
+	var res ImportResult
+	err := Modify(s, "import", func(tasks []Task) ([]Task, error) {
+		res = ImportResult{}
+		// Imported UUIDs that turned out to be tasks already here, so
+		// their subtasks point at those
+		same := map[string]string{}
+		// Tasks already matched, or added, by this import
+		claimed := map[int]bool{}
+		for _, in := range imported {
+			if uuid, ok := same[in.Parent]; ok {
+				in.Parent = uuid
+			}
+			i := indexOfImported(tasks, in, claimed)
+			if i >= 0 && in.UUID != "" {
+				same[in.UUID] = tasks[i].UUID
+			}
+			switch {
+			case i < 0:
+				in.ID = NextID(tasks)
+				if in.UUID == "" {
+					in.UUID = newUUID()
+				}
+				in.Modified = nil
+				claimed[len(tasks)] = true
+				tasks = append(tasks, in)
+				res.Added++
+			case importChanges(tasks[i], in):
+				applyImport(&tasks[i], in)
+				claimed[i] = true
+				res.Updated++
+			default:
+				claimed[i] = true
+				res.Unchanged++
+			}
+		}
+		return tasks, nil
+	})
+	return res, err
+}
+
+// indexOfImported finds the task an imported one corresponds to: the one
+// with its UUID, or else one not yet claimed that the import wouldn't
+// change
+func indexOfImported(tasks []Task, in Task, claimed map[int]bool) int {
+	for i, t := range tasks {
+		if in.UUID != "" && t.UUID == in.UUID {
+			return i
+		}
+	}
+	for i, t := range tasks {
+		if !claimed[i] && !importChanges(t, in) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyImport copies an imported task's fields onto t. Extra is merged
+// key by key, so what other formats keep there (Extra["csv"], say) stays.
+func applyImport(t *Task, in Task) {
+	extra := t.Extra
+	for _, f := range syncFields {
+		f.set(t, in)
+	}
+	if len(extra) == 0 {
+		return
+	}
+	merged := make(map[string]json.RawMessage, len(extra)+len(in.Extra))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range in.Extra {
+		merged[k] = v
+	}
+	t.Extra = merged
+}
+
+// importChanges reports whether importing in over t would change t
+func importChanges(t, in Task) bool {
+	after := cloneTasks([]Task{t})[0]
+	applyImport(&after, in)
+	return !sameFields(t, after)
+}
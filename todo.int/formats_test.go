@@ -0,0 +1,59 @@
+package todo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestImportTasks(t *testing.T) {
+	s := NewMemoryStore()
+	file := []byte("Buy milk\nBuy milk\n(A) Call mom id:7\n")
+	imported, err := DecodeTodoTxt(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ImportTasks(s, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (ImportResult{Added: 3}) {
+		t.Errorf("first import: %+v, want 3 added", res)
+	}
+
+	// Importing the same file again changes nothing, and the repeated
+	// line matches the second task instead of adding a third
+	res, err = ImportTasks(s, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (ImportResult{Unchanged: 3}) {
+		t.Errorf("second import: %+v, want 3 unchanged", res)
+	}
+
+	// A CSV import of the same task adds its own Extra key next to the
+	// todo.txt one
+	tasks, _ := s.Load()
+	csvTasks, err := DecodeCSV([]byte("uuid,text,priority,owner\n" + tasks[2].UUID + ",Call mom,high,sam\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err = ImportTasks(s, csvTasks); err != nil {
+		t.Fatal(err)
+	}
+	if res != (ImportResult{Updated: 1}) {
+		t.Errorf("csv import: %+v, want 1 updated", res)
+	}
+	tasks, _ = s.Load()
+	if len(tasks) != 3 {
+		t.Fatalf("%d tasks after importing, want 3", len(tasks))
+	}
+	var keys todoTxtExtra
+	_ = json.Unmarshal(tasks[2].Extra["todotxt"], &keys)
+	if len(keys.Keys) != 1 || keys.Keys[0] != "id:7" {
+		t.Errorf("todotxt extra = %s, want id:7 kept", tasks[2].Extra["todotxt"])
+	}
+	if string(tasks[2].Extra["csv"]) != `{"owner":"sam"}` {
+		t.Errorf("csv extra = %s", tasks[2].Extra["csv"])
+	}
+}
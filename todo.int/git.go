@@ -0,0 +1,145 @@
+package todo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitRepo commits the task file and its sidecars to a git repository after
+// every change, so the list can be reviewed and reverted with plain git
+// tooling
+type GitRepo struct {
+	Dir  string // root of the repository
+	File string // task file, relative to Dir
+}
+
+// GitRepoFor returns the repository for the task file at dataPath: the one
+// in the directory of the default task file at basePath. Named lists share
+// it, so they don't each get a repository of their own. A repository the
+// directory merely sits inside is never used, so todo's commits don't end
+// up in someone's dotfiles or project history.
+func GitRepoFor(basePath, dataPath string) *GitRepo {
+	dir := filepath.Dir(basePath)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	g := &GitRepo{Dir: dir}
+
+	// dataPath's directory may not exist yet, so resolve it the same way
+	// from its parent
+	file, err := filepath.Abs(dataPath)
+	if err == nil {
+		if real, err := filepath.EvalSymlinks(filepath.Dir(file)); err == nil {
+			file = filepath.Join(real, filepath.Base(file))
+		}
+		file, err = filepath.Rel(g.Dir, file)
+	}
+	if err != nil || strings.HasPrefix(file, ".."+string(filepath.Separator)) {
+		return &GitRepo{Dir: filepath.Dir(dataPath), File: filepath.Base(dataPath)}
+	}
+	g.File = file
+	return g
+}
+
+// Record commits the task file and its sidecars with a message describing
+// the change, e.g. "done: 4 Write documentation". The repository is
+// created on first use.
+func (g *GitRepo) Record(c Change) error {
+	if len(c.Diffs()) == 0 {
+		return nil
+	}
+	if err := g.ensureRepo(); err != nil {
+		return err
+	}
+	files := g.files()
+	if _, err := g.git(append([]string{"add", "--"}, files...)...); err != nil {
+		return err
+	}
+	_, err := g.git(append([]string{"commit", "--quiet", "-m", c.String(), "--"}, files...)...)
+	return err
+}
+
+// files are the task file and those of its sidecars that exist, relative
+// to Dir
+func (g *GitRepo) files() []string {
+	files := []string{g.File}
+	for _, name := range sidecarNames {
+		sidecar := SidecarPath(g.File, name)
+		if _, err := os.Stat(filepath.Join(g.Dir, sidecar)); err == nil {
+			files = append(files, sidecar)
+		}
+	}
+	return files
+}
+
+// Log returns the commit history of the task file, newest first
+func (g *GitRepo) Log(limit int) (string, error) {
+	args := []string{"log", "--date=format:%Y-%m-%d %H:%M", "--format=%h  %ad  %s"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("-n%d", limit))
+	}
+	return g.git(append(args, "--", g.File)...)
+}
+
+// Show returns the change a single commit made to the task file
+func (g *GitRepo) Show(rev string) (string, error) {
+	return g.git("show", "--format=%h  %ad  %s%n", "--date=format:%Y-%m-%d %H:%M", rev, "--", g.File)
+}
+
+// ensureRepo creates the repository unless Dir already has one of its own
+func (g *GitRepo) ensureRepo() error {
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); err == nil {
+		return nil
+	}
+	if _, err := g.git("init", "--quiet"); err != nil {
+		return err
+	}
+	ignore := filepath.Join(g.Dir, ".gitignore")
+	if _, err := os.Stat(ignore); err == nil {
+		return nil
+	}
+	return os.WriteFile(ignore, []byte("*.lock\n"), 0644)
+}
+
+func (g *GitRepo) git(args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git not found")
+	}
+	cmd := exec.Command("git", append([]string{"-C", g.Dir}, args...)...)
+	cmd.Env = append(os.Environ(), gitIdentityEnv(g.Dir)...)
+	// Stop git looking above Dir for a repository to use
+	if abs, err := filepath.Abs(g.Dir); err == nil {
+		cmd.Env = append(cmd.Env, "GIT_CEILING_DIRECTORIES="+filepath.Dir(abs))
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return string(out), nil
+}
+
+// gitIdentityEnv supplies a fallback author when git has none configured,
+// so commits don't fail on a fresh machine
+func gitIdentityEnv(dir string) []string {
+	out, err := exec.Command("git", "-C", dir, "config", "user.email").Output()
+	if err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return nil
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=todo", "GIT_AUTHOR_EMAIL=todo@localhost",
+		"GIT_COMMITTER_NAME=todo", "GIT_COMMITTER_EMAIL=todo@localhost",
+	}
+}
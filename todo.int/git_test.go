@@ -0,0 +1,50 @@
+package todo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitRepoIgnoresEnclosingRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// The data directory sits inside someone's project repository
+	outer := t.TempDir()
+	if out, err := exec.Command("git", "-C", outer, "init", "--quiet").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	dir := filepath.Join(outer, "data")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "tasks.json")
+	repo := GitRepoFor(path, path)
+	s := Track(NewJSONStore(path), repo)
+
+	if _, err := repo.Log(0); err == nil {
+		t.Error("Log read the enclosing repository before todo made one")
+	}
+	for _, text := range []string{"Buy milk", "Call mom"} {
+		if err := AddTaskWithDueDate(s, text, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		t.Errorf("no repository of its own in the data directory: %v", err)
+	}
+	if out, err := exec.Command("git", "-C", outer, "log", "--oneline").CombinedOutput(); err == nil {
+		t.Errorf("commits landed in the enclosing repository:\n%s", out)
+	}
+	log, err := repo.Log(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(log), "\n"); len(lines) != 2 || !strings.Contains(lines[0], "Call mom") {
+		t.Errorf("log = %q, want two commits, newest first", log)
+	}
+}
@@ -0,0 +1,265 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxHistory is how many operations undo can walk back through
+const maxHistory = 100
+
+// ErrNothingToUndo is returned when the undo stack is empty
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrNothingToRedo is returned when there is nothing to redo
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// History keeps undo/redo stacks of reversible operations in a JSON file
+// next to the task file
+type History struct {
+	Path       string
+	Encryption *Encryption
+}
+
+type historyEntry struct {
+	Op      string     `json:"op"`
+	Summary string     `json:"summary"`
+	Time    time.Time  `json:"time"`
+	Diffs   []TaskDiff `json:"diffs"`
+}
+
+type historyFile struct {
+	Undo []historyEntry `json:"undo"`
+	Redo []historyEntry `json:"redo"`
+}
+
+// SidecarPath returns a file that lives next to the task file, e.g.
+// (tasks.json, "history.json") -> tasks.json.history.json. The extension
+// stays in the name so tasks.json and tasks.db keep separate sidecars.
+func SidecarPath(dataPath, name string) string {
+	return dataPath + "." + name
+}
+
+// sidecarNames are the files SidecarPath names next to a task file
+var sidecarNames = []string{"history.json", "journal.jsonl", "trash.json", "archive.json", "sync.json", "caldav.json"}
+
+// MigrateSidecars renames the sidecars and backups of the task file at
+// dataPath from the old naming, which left out the extension
+// (tasks.history.json), to SidecarPath's. The old files were shared by
+// tasks.json and tasks.db; they go to the JSON file when there is one.
+func MigrateSidecars(dataPath string) error {
+	ext := filepath.Ext(dataPath)
+	if ext == "" {
+		return nil
+	}
+	stem := strings.TrimSuffix(dataPath, ext)
+	if ext != ".json" {
+		if _, err := os.Stat(stem + ".json"); err == nil {
+			return nil
+		}
+	}
+	for _, name := range sidecarNames {
+		if err := renameIfAbsent(stem+"."+name, SidecarPath(dataPath, name)); err != nil {
+			return err
+		}
+	}
+
+	backups := BackupsFor(dataPath)
+	entries, err := os.ReadDir(backups.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	oldPrefix := filepath.Base(stem) + "-"
+	for _, e := range entries {
+		rest, ok := strings.CutPrefix(e.Name(), oldPrefix)
+		if !ok || len(rest) < len(backupStamp) {
+			continue
+		}
+		if _, err := time.Parse(backupStamp, rest[:len(backupStamp)]); err != nil {
+			continue
+		}
+		if err := renameIfAbsent(filepath.Join(backups.Dir, e.Name()), filepath.Join(backups.Dir, backups.Name+"-"+rest)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameIfAbsent moves from to to, unless from is missing or to is there
+func renameIfAbsent(from, to string) error {
+	if _, err := os.Lstat(from); err != nil {
+		return nil
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return nil
+	}
+	return os.Rename(from, to)
+}
+
+// HistoryFor returns the undo history that belongs to the task file at
+// dataPath
+func HistoryFor(dataPath string) *History {
+	return &History{Path: SidecarPath(dataPath, "history.json")}
+}
+
+// Record pushes a change onto the undo stack and clears the redo stack.
+// Changes made by Undo and Redo themselves are not recorded.
+func (h *History) Record(c Change) error {
+	if c.Op == "undo" || c.Op == "redo" {
+		return nil
+	}
+	diffs := c.Diffs()
+	if len(diffs) == 0 {
+		return nil
+	}
+	f, err := h.load()
+	if err != nil {
+		return err
+	}
+	f.Undo = append(f.Undo, historyEntry{Op: c.Op, Summary: c.String(), Time: c.Time, Diffs: diffs})
+	if len(f.Undo) > maxHistory {
+		f.Undo = f.Undo[len(f.Undo)-maxHistory:]
+	}
+	f.Redo = nil
+	return h.save(f)
+}
+
+// Undo reverts the most recent operation and returns its summary
+func (h *History) Undo(s Store) (string, error) {
+	return h.step(s, "undo")
+}
+
+// Redo re-applies the most recently undone operation and returns its summary
+func (h *History) Redo(s Store) (string, error) {
+	return h.step(s, "redo")
+}
+
+// step pops one entry off the undo (or redo) stack and applies it. The
+// history file is read and written under the store's lock so it can't race
+// with changes being recorded by another process, and only written once
+// the tasks are saved, so a failed save leaves the entry where it was.
+func (h *History) step(s Store, op string) (string, error) {
+	var summary string
+	err := WithLock(s, func() error {
+		var f historyFile
+		err := modify(s, op, func(tasks []Task) ([]Task, error) {
+			var err error
+			if f, err = h.load(); err != nil {
+				return nil, err
+			}
+			from, to := &f.Undo, &f.Redo
+			if op == "redo" {
+				from, to = &f.Redo, &f.Undo
+			}
+			if len(*from) == 0 {
+				if op == "redo" {
+					return nil, ErrNothingToRedo
+				}
+				return nil, ErrNothingToUndo
+			}
+
+			entry := (*from)[len(*from)-1]
+			*from = (*from)[:len(*from)-1]
+			*to = append(*to, entry)
+			summary = entry.Summary
+			return applyDiffs(tasks, entry.Diffs, op == "undo"), nil
+		})
+		// A recorder failing after the save doesn't undo the step
+		var rec recordError
+		if err != nil && !errors.As(err, &rec) {
+			return err
+		}
+		if serr := h.save(f); serr != nil {
+			return serr
+		}
+		return err
+	})
+	return summary, err
+}
+
+// applyDiffs replays diffs onto tasks, or reverts them when reverse is set.
+// Tasks are matched by UUID so unrelated edits made since are left alone.
+func applyDiffs(tasks []Task, diffs []TaskDiff, reverse bool) []Task {
+	type insert struct {
+		task  Task
+		index int
+	}
+	var inserts []insert
+
+	for _, d := range diffs {
+		from, to := d.Before, d.After
+		if reverse {
+			from, to = d.After, d.Before
+		}
+		switch {
+		case to == nil:
+			tasks = removeByUUID(tasks, from.UUID)
+		case from == nil:
+			inserts = append(inserts, insert{task: *to, index: d.Index})
+		default:
+			replaced := false
+			for i := range tasks {
+				if tasks[i].UUID == to.UUID {
+					tasks[i] = *to
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				inserts = append(inserts, insert{task: *to, index: d.Index})
+			}
+		}
+	}
+
+	sort.SliceStable(inserts, func(i, j int) bool { return inserts[i].index < inserts[j].index })
+	for _, in := range inserts {
+		tasks = removeByUUID(tasks, in.task.UUID)
+		if _, err := findByID(tasks, in.task.ID); err == nil {
+			in.task.ID = NextID(tasks)
+		}
+		i := in.index
+		if i > len(tasks) {
+			i = len(tasks)
+		}
+		tasks = append(tasks[:i], append([]Task{in.task}, tasks[i:]...)...)
+	}
+	return tasks
+}
+
+func removeByUUID(tasks []Task, uuid string) []Task {
+	for i := range tasks {
+		if tasks[i].UUID == uuid {
+			return append(tasks[:i], tasks[i+1:]...)
+		}
+	}
+	return tasks
+}
+
+func (h *History) load() (historyFile, error) {
+	var f historyFile
+	data, err := readSealed(h.Path, h.Encryption)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, nil
+		}
+		return f, err
+	}
+	err = json.Unmarshal(data, &f)
+	return f, err
+}
+
+func (h *History) save(f historyFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSealed(h.Path, data, h.Encryption)
+}
@@ -0,0 +1,111 @@
+package todo
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// flakyStore fails its saves while fail is set
+type flakyStore struct {
+	*MemoryStore
+	fail bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (s *flakyStore) Save(tasks []Task) error {
+	if s.fail {
+		return errDiskFull
+	}
+	return s.MemoryStore.Save(tasks)
+}
+
+func taskTexts(t *testing.T, s Store) []string {
+	t.Helper()
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{}
+	for _, task := range tasks {
+		texts = append(texts, task.Text)
+	}
+	return texts
+}
+
+func TestUndoRedo(t *testing.T) {
+	h := HistoryFor(filepath.Join(t.TempDir(), "tasks.json"))
+	s := Track(NewMemoryStore(), h)
+	for _, text := range []string{"Buy milk", "Call mom"} {
+		if err := AddTaskWithDueDate(s, text, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MarkTaskDone(s, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		step    func(Store) (string, error)
+		want    []string
+		done    bool // task 1 is done afterwards
+		wantErr error
+	}{
+		{h.Undo, []string{"Buy milk", "Call mom"}, false, nil},
+		{h.Undo, []string{"Buy milk"}, false, nil},
+		{h.Redo, []string{"Buy milk", "Call mom"}, false, nil},
+		{h.Redo, []string{"Buy milk", "Call mom"}, true, nil},
+		{h.Redo, []string{"Buy milk", "Call mom"}, true, ErrNothingToRedo},
+		{h.Undo, []string{"Buy milk", "Call mom"}, false, nil},
+		{h.Undo, []string{"Buy milk"}, false, nil},
+		{h.Undo, []string{}, false, nil},
+		{h.Undo, []string{}, false, ErrNothingToUndo},
+	}
+	for i, st := range steps {
+		_, err := st.step(s)
+		if !errors.Is(err, st.wantErr) {
+			t.Fatalf("step %d: error %v, want %v", i, err, st.wantErr)
+		}
+		if got := taskTexts(t, s); !reflect.DeepEqual(got, st.want) {
+			t.Fatalf("step %d: tasks %q, want %q", i, got, st.want)
+		}
+		if tasks, _ := s.Load(); len(tasks) > 0 && tasks[0].Completed != st.done {
+			t.Errorf("step %d: task 1 done = %v, want %v", i, tasks[0].Completed, st.done)
+		}
+	}
+
+	// A new change after undoing clears what could be redone
+	if err := AddTaskWithDueDate(s, "Pay rent", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Redo(s); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("redo after a new change: %v, want ErrNothingToRedo", err)
+	}
+}
+
+func TestUndoKeepsEntryWhenSaveFails(t *testing.T) {
+	h := HistoryFor(filepath.Join(t.TempDir(), "tasks.json"))
+	base := &flakyStore{MemoryStore: NewMemoryStore()}
+	s := Track(base, h)
+	if err := AddTaskWithDueDate(s, "Buy milk", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	base.fail = true
+	if _, err := h.Undo(s); !errors.Is(err, errDiskFull) {
+		t.Fatalf("undo with a failing save: %v, want %v", err, errDiskFull)
+	}
+	if _, err := h.Redo(s); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("the failed undo was moved to the redo stack (redo: %v)", err)
+	}
+
+	base.fail = false
+	if _, err := h.Undo(s); err != nil {
+		t.Fatalf("undo once the save works: %v", err)
+	}
+	if got := taskTexts(t, s); len(got) != 0 {
+		t.Errorf("tasks after undo = %q, want none", got)
+	}
+}
@@ -0,0 +1,583 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iCalendar (RFC 5545) VTODO mapping:
+//
+//	UUID      UID
+//	Text      SUMMARY
+//	DueDate   DUE (a date, or a date and time with DueTime)
+//	Duration  X-TODO-DURATION (a VTODO can only have DURATION with a start)
+//	Completed STATUS (NEEDS-ACTION / COMPLETED) and COMPLETED
+//	Tags      CATEGORIES
+//	Priority  PRIORITY (high 1, medium 5, low 9)
+//	Recurring RRULE
+//	Parent    RELATED-TO (RELTYPE=PARENT)
+//
+// Priorities and recurrence rules RRULE can't express exactly are kept
+// verbatim in X-TODO-PRIORITY and X-TODO-RECURRING, so they survive a
+// round trip through a calendar app that leaves unknown properties alone.
+// Likewise, properties and alarms the mapping doesn't cover are kept in
+// Task.Extra under "ical" and written back out.
+
+const (
+	icalDate     = "20060102"
+	icalDateTime = "20060102T150405Z"
+)
+
+// EncodeCalendar writes tasks as a VCALENDAR of VTODOs
+func EncodeCalendar(tasks []Task) []byte {
+	return encodeCalendar(tasks, false)
+}
+
+// EncodeICS writes tasks as a calendar to subscribe to: tasks due at a
+// time become VEVENTs from DueTime for Duration, the rest all-day VTODOs
+func EncodeICS(tasks []Task) ([]byte, error) {
+	return encodeCalendar(tasks, true), nil
+}
+
+func encodeCalendar(tasks []Task, events bool) []byte {
+	var b bytes.Buffer
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//i-ape//todo//EN")
+	now := time.Now()
+	for _, t := range tasks {
+		if _, timed := t.DueAt(); timed && events {
+			writeComponent(&b, "VEVENT", t, now)
+		} else {
+			writeComponent(&b, "VTODO", t, now)
+		}
+	}
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.Bytes()
+}
+
+// writeComponent writes t as a VTODO, or as a VEVENT if it has a due time
+func writeComponent(b *bytes.Buffer, kind string, t Task, now time.Time) {
+	writeICalLine(b, "BEGIN:"+kind)
+	writeICalLine(b, "UID:"+t.UUID)
+	writeICalLine(b, "DTSTAMP:"+now.UTC().Format(icalDateTime))
+	if at := lastModified(t); !at.IsZero() {
+		writeICalLine(b, "LAST-MODIFIED:"+at.UTC().Format(icalDateTime))
+	}
+	writeICalLine(b, "SUMMARY:"+escapeICalText(t.Text))
+
+	dur, _ := time.ParseDuration(t.Duration)
+	if kind == "VEVENT" {
+		start, _ := t.DueAt()
+		writeICalLine(b, "DTSTART:"+start.UTC().Format(icalDateTime))
+		if dur > 0 {
+			writeICalLine(b, "DURATION:"+formatICalDuration(dur))
+		}
+		if t.Completed {
+			writeICalLine(b, "X-TODO-COMPLETED:"+t.CompletedAt)
+		}
+	} else {
+		if at, ok := t.DueAt(); ok {
+			writeICalLine(b, "DUE:"+at.UTC().Format(icalDateTime))
+		} else if due, err := time.Parse("2006-01-02", t.DueDate); err == nil {
+			writeICalLine(b, "DUE;VALUE=DATE:"+due.Format(icalDate))
+		}
+		if dur > 0 {
+			writeICalLine(b, "X-TODO-DURATION:"+t.Duration)
+		}
+		if t.Completed {
+			writeICalLine(b, "STATUS:COMPLETED")
+			if at, ok := t.CompletedTime(); ok {
+				writeICalLine(b, "COMPLETED:"+at.UTC().Format(icalDateTime))
+			}
+		} else {
+			writeICalLine(b, "STATUS:NEEDS-ACTION")
+		}
+	}
+	if len(t.Tags) > 0 {
+		escaped := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			escaped[i] = escapeICalText(tag)
+		}
+		writeICalLine(b, "CATEGORIES:"+strings.Join(escaped, ","))
+	}
+	if t.Priority != "" {
+		n := icalPriority(t.Priority)
+		writeICalLine(b, "PRIORITY:"+strconv.Itoa(n))
+		if taskPriority(n) != t.Priority {
+			writeICalLine(b, "X-TODO-PRIORITY:"+escapeICalText(t.Priority))
+		}
+	}
+	if t.Recurring != "" {
+		rule := recurrenceToRRule(t.Recurring)
+		if rule != "" {
+			writeICalLine(b, "RRULE:"+rule)
+		}
+		if rule == "" || rruleToRecurrence(rule) != t.Recurring {
+			writeICalLine(b, "X-TODO-RECURRING:"+escapeICalText(t.Recurring))
+		}
+	}
+	if t.Parent != "" {
+		writeICalLine(b, "RELATED-TO;RELTYPE=PARENT:"+t.Parent)
+	}
+	var extra []string
+	if raw, ok := t.Extra["ical"]; ok && json.Unmarshal(raw, &extra) == nil {
+		for _, line := range extra {
+			writeICalLine(b, line)
+		}
+	}
+	writeICalLine(b, "END:"+kind)
+}
+
+// DecodeCalendar reads the VTODOs in an iCalendar file, and the VEVENTs
+// EncodeICS writes for tasks due at a time. Tasks get no display IDs; the
+// caller assigns them.
+func DecodeCalendar(data []byte) ([]Task, error) {
+	var tasks []Task
+	var cur *Task
+	var rrule, rawRecurring, rawPriority string
+	var priority int
+	var lastMod, end time.Time
+	var extra []string
+	var kind string
+	depth := 0 // inside an alarm or other component nested in the VTODO
+
+	for _, line := range unfoldICal(data) {
+		name, params, value := splitICalLine(line)
+		if name == "BEGIN" && (value == "VTODO" || value == "VEVENT") && cur == nil {
+			cur, kind = &Task{}, value
+			rrule, rawRecurring, rawPriority, priority = "", "", "", 0
+			lastMod, end, extra = time.Time{}, time.Time{}, nil
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if name == "BEGIN" {
+			depth++
+		}
+		if depth > 0 {
+			if name == "END" {
+				depth--
+			}
+			extra = append(extra, line)
+			continue
+		}
+		switch name {
+		case "END":
+			if start, ok := cur.DueAt(); ok && end.After(start) && cur.Duration == "" {
+				cur.Duration = shortDuration(end.Sub(start))
+			}
+			if rawPriority != "" && icalPriority(rawPriority) == priority {
+				cur.Priority = rawPriority
+			} else {
+				cur.Priority = taskPriority(priority)
+			}
+			cur.Recurring = rruleToRecurrence(rrule)
+			if rawRecurring != "" && recurrenceToRRule(rawRecurring) == rrule {
+				cur.Recurring = rawRecurring
+			}
+			if !lastMod.IsZero() {
+				cur.Modified = map[string]time.Time{}
+				for _, f := range syncFields {
+					cur.Modified[f.name] = lastMod
+				}
+			}
+			if cur.UUID == "" {
+				cur.UUID = newUUID()
+			}
+			if extra != nil {
+				raw, _ := json.Marshal(extra)
+				cur.Extra = map[string]json.RawMessage{"ical": raw}
+			}
+			tasks = append(tasks, *cur)
+			cur = nil
+		case "UID":
+			cur.UUID = value
+		case "SUMMARY":
+			cur.Text = unescapeICalText(value)
+		case "DUE":
+			setICalDue(cur, value, params)
+		case "DTSTART":
+			if kind == "VEVENT" {
+				setICalDue(cur, value, params)
+			} else {
+				extra = append(extra, line)
+			}
+		case "DURATION":
+			if d, err := parseICalDuration(value); err == nil && kind == "VEVENT" {
+				cur.Duration = shortDuration(d)
+			} else {
+				extra = append(extra, line)
+			}
+		case "DTEND":
+			if at, err := parseICalTime(value, params); err == nil && kind == "VEVENT" {
+				end = at
+			} else {
+				extra = append(extra, line)
+			}
+		case "RELATED-TO":
+			if rel, ok := params["RELTYPE"]; (!ok || strings.EqualFold(rel, "PARENT")) && cur.Parent == "" {
+				cur.Parent = value
+			} else {
+				extra = append(extra, line)
+			}
+		case "X-TODO-DURATION":
+			if d, err := time.ParseDuration(value); err == nil {
+				cur.Duration = shortDuration(d)
+			}
+		case "X-TODO-COMPLETED":
+			cur.Completed = true
+			cur.CompletedAt = value
+		case "STATUS":
+			cur.Completed = strings.EqualFold(value, "COMPLETED")
+		case "COMPLETED":
+			if at, err := parseICalTime(value, params); err == nil {
+				cur.Completed = true
+				cur.CompletedAt = at.UTC().Format(time.RFC3339)
+			}
+		case "CATEGORIES":
+			for _, tag := range splitICalList(value) {
+				if tag = unescapeICalText(tag); tag != "" {
+					cur.Tags = append(cur.Tags, tag)
+				}
+			}
+		case "PRIORITY":
+			priority, _ = strconv.Atoi(value)
+		case "X-TODO-PRIORITY":
+			rawPriority = unescapeICalText(value)
+		case "RRULE":
+			rrule = value
+		case "X-TODO-RECURRING":
+			rawRecurring = unescapeICalText(value)
+		case "LAST-MODIFIED":
+			if at, err := parseICalTime(value, params); err == nil {
+				lastMod = at
+			}
+		case "DTSTAMP":
+			// Rewritten on every export
+		default:
+			extra = append(extra, line)
+		}
+	}
+	if tasks == nil && !bytes.Contains(data, []byte("BEGIN:VCALENDAR")) {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+	return tasks, nil
+}
+
+// setICalDue sets the due date, and time if it has one, from a DUE or
+// DTSTART value
+func setICalDue(t *Task, value string, params map[string]string) {
+	at, err := parseICalTime(value, params)
+	if err != nil {
+		return
+	}
+	if params["VALUE"] == "DATE" || len(value) == len(icalDate) {
+		t.DueDate, t.DueTime = at.Format("2006-01-02"), ""
+		return
+	}
+	at = at.Local()
+	t.DueDate, t.DueTime = at.Format("2006-01-02"), at.Format("15:04")
+}
+
+// formatICalDuration writes d as an RFC 5545 duration, like PT1H30M
+func formatICalDuration(d time.Duration) string {
+	out := "PT"
+	if h := int(d.Hours()); h > 0 {
+		out += strconv.Itoa(h) + "H"
+	}
+	if m := int(d.Minutes()) % 60; m > 0 {
+		out += strconv.Itoa(m) + "M"
+	}
+	if s := int(d.Seconds()) % 60; s > 0 || out == "PT" {
+		out += strconv.Itoa(s) + "S"
+	}
+	return out
+}
+
+var icalDurationPattern = regexp.MustCompile(`^\+?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICalDuration reads an RFC 5545 duration. Negative ones (alarm
+// offsets) aren't task durations and are refused.
+func parseICalDuration(s string) (time.Duration, error) {
+	m := icalDurationPattern.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			d += time.Duration(n) * unit
+		}
+	}
+	return d, nil
+}
+
+func icalPriority(p string) int {
+	switch strings.ToLower(p) {
+	case "high":
+		return 1
+	case "medium":
+		return 5
+	case "low":
+		return 9
+	}
+	return 0
+}
+
+func taskPriority(n int) string {
+	switch {
+	case n >= 1 && n <= 4:
+		return "high"
+	case n == 5:
+		return "medium"
+	case n >= 6 && n <= 9:
+		return "low"
+	}
+	return ""
+}
+
+var rruleDays = []struct{ name, code string }{
+	{"sun", "SU"}, {"mon", "MO"}, {"tue", "TU"}, {"wed", "WE"},
+	{"thu", "TH"}, {"fri", "FR"}, {"sat", "SA"},
+}
+
+// recurrenceToRRule translates rules like "daily", "every 2 weeks",
+// "every weekday" or "every mon,wed,fri @ 09:00" to an RRULE. Anything
+// else gives "". The time of day is left to DUE or DTSTART, which say
+// which time zone it is in.
+func recurrenceToRRule(rec string) string {
+	rec, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(rec)), " @ ")
+	return rruleFreq(rec)
+}
+
+func rruleFreq(rec string) string {
+	switch rec {
+	case "daily", "every day":
+		return "FREQ=DAILY"
+	case "weekly", "every week":
+		return "FREQ=WEEKLY"
+	case "monthly", "every month":
+		return "FREQ=MONTHLY"
+	case "yearly", "every year":
+		return "FREQ=YEARLY"
+	case "every weekday":
+		return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+	}
+	rest, ok := strings.CutPrefix(rec, "every ")
+	if !ok {
+		return ""
+	}
+	if fields := strings.Fields(rest); len(fields) == 2 {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil {
+			freq := map[string]string{"days": "DAILY", "weeks": "WEEKLY", "months": "MONTHLY", "years": "YEARLY"}[fields[1]]
+			if freq != "" {
+				return fmt.Sprintf("FREQ=%s;INTERVAL=%d", freq, n)
+			}
+		}
+		return ""
+	}
+	var days []string
+	for _, d := range strings.Split(rest, ",") {
+		d = strings.TrimSpace(d)
+		wd, ok := weekdayNames[d]
+		if !ok {
+			return ""
+		}
+		days = append(days, rruleDays[wd].code)
+	}
+	return "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ",")
+}
+
+// rruleToRecurrence is the reverse of recurrenceToRRule. Rules it has no
+// words for are kept as the RRULE itself.
+func rruleToRecurrence(rule string) string {
+	if rule == "" {
+		return ""
+	}
+	parts := map[string]string{}
+	for _, p := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			parts[strings.ToUpper(k)] = strings.ToUpper(v)
+		}
+	}
+	at := ""
+	if h, ok := parts["BYHOUR"]; ok {
+		hour, err1 := strconv.Atoi(h)
+		minute, err2 := strconv.Atoi(parts["BYMINUTE"])
+		if err1 != nil || err2 != nil {
+			return rule
+		}
+		at = fmt.Sprintf(" @ %02d:%02d", hour, minute)
+		delete(parts, "BYHOUR")
+		delete(parts, "BYMINUTE")
+	}
+	rec := rruleWords(rule, parts)
+	if rec == rule {
+		return rule
+	}
+	return rec + at
+}
+
+func rruleWords(rule string, parts map[string]string) string {
+	interval := parts["INTERVAL"]
+	delete(parts, "INTERVAL")
+	if interval == "1" {
+		interval = ""
+	}
+	freq, byday := parts["FREQ"], parts["BYDAY"]
+	if len(parts) > 2 || (len(parts) == 2 && byday == "") {
+		return rule
+	}
+
+	units := map[string][2]string{
+		"DAILY": {"daily", "days"}, "WEEKLY": {"weekly", "weeks"},
+		"MONTHLY": {"monthly", "months"}, "YEARLY": {"yearly", "years"},
+	}
+	unit, ok := units[freq]
+	switch {
+	case !ok:
+		return rule
+	case byday == "" && interval == "":
+		return unit[0]
+	case byday == "":
+		return "every " + interval + " " + unit[1]
+	case freq != "WEEKLY" || interval != "":
+		return rule
+	case byday == "MO,TU,WE,TH,FR":
+		return "every weekday"
+	}
+	var names []string
+	for _, code := range strings.Split(byday, ",") {
+		found := false
+		for _, d := range rruleDays {
+			if d.code == code {
+				names = append(names, d.name)
+				found = true
+			}
+		}
+		if !found {
+			return rule
+		}
+	}
+	return "every " + strings.Join(names, ",")
+}
+
+// writeICalLine writes a content line, folded so no line is over 75
+// octets, counting the space each continuation line starts with
+func writeICalLine(b *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8Start(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func utf8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+func unfoldICal(data []byte) []string {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitICalLine splits "NAME;PARAM=x:value" into its parts
+func splitICalLine(line string) (name string, params map[string]string, value string) {
+	params = map[string]string{}
+	head, value, _ := strings.Cut(line, ":")
+	fields := strings.Split(head, ";")
+	for _, p := range fields[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(fields[0]), params, value
+}
+
+func parseICalTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icalDate) {
+		return time.ParseInLocation(icalDate, value, time.Local)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icalDateTime, value)
+	}
+	loc := time.Local
+	if tz, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeICalText(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// splitICalList splits a comma-separated value, leaving escaped commas
+func splitICalList(s string) []string {
+	var out []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == ',' {
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
@@ -0,0 +1,166 @@
+package todo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSetDue(t *testing.T) {
+	tests := []struct {
+		input              string
+		date, at, duration string
+		wantErr            bool
+	}{
+		{input: "2026-10-20", date: "2026-10-20"},
+		{input: "2026-10-20 @ 18:00", date: "2026-10-20", at: "18:00"},
+		{input: "2026-10-20 @ 18:00 for 1h", date: "2026-10-20", at: "18:00", duration: "1h"},
+		{input: "2026-10-20 @ 9:05 for 90m", date: "2026-10-20", at: "09:05", duration: "1h30m"},
+		{input: "2026-10-20 @ 18:00 for 45m", date: "2026-10-20", at: "18:00", duration: "45m"},
+		{input: ""},
+		{input: "2026-10-20 @ 25:00", wantErr: true},
+		{input: "2026-10-20 @ 18:00 for a while", wantErr: true},
+		{input: "2026-10-20 for 1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		task := Task{DueDate: "2026-01-01", DueTime: "08:00", Duration: "1h"}
+		err := task.SetDue(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetDue(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if task.DueDate != tt.date || task.DueTime != tt.at || task.Duration != tt.duration {
+			t.Errorf("SetDue(%q) = %q %q %q, want %q %q %q", tt.input,
+				task.DueDate, task.DueTime, task.Duration, tt.date, tt.at, tt.duration)
+		}
+	}
+}
+
+// icalComponents splits an unfolded calendar into its components' lines,
+// keyed by UID
+func icalComponents(t *testing.T, data []byte) map[string][]string {
+	t.Helper()
+	comps := map[string][]string{}
+	var cur []string
+	for _, line := range unfoldICal(data) {
+		switch {
+		case line == "BEGIN:VTODO" || line == "BEGIN:VEVENT":
+			cur = []string{line}
+		case cur == nil:
+		case strings.HasPrefix(line, "END:"):
+			for _, l := range cur {
+				if uid, ok := strings.CutPrefix(l, "UID:"); ok {
+					comps[uid] = cur
+				}
+			}
+			cur = nil
+		default:
+			cur = append(cur, line)
+		}
+	}
+	return comps
+}
+
+func TestEncodeICSEvents(t *testing.T) {
+	utc := func(date, at string) string {
+		local, err := time.ParseInLocation("2006-01-02 15:04", date+" "+at, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return local.UTC().Format(icalDateTime)
+	}
+	tasks := []Task{
+		{UUID: "meeting", Text: "Team meeting", DueDate: "2026-10-20", DueTime: "18:00", Duration: "1h30m"},
+		{UUID: "call", Text: "Call mom", DueDate: "2026-10-21", DueTime: "09:05"},
+		{UUID: "report", Text: "Write report", DueDate: "2026-10-22"},
+		{UUID: "rent", Text: "Pay rent", DueDate: "2026-11-01", Recurring: "monthly"},
+	}
+	data, err := EncodeICS(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := icalComponents(t, data)
+
+	tests := []struct {
+		uid      string
+		want     []string
+		dontWant []string // property names
+	}{
+		{"meeting", []string{"BEGIN:VEVENT", "DTSTART:" + utc("2026-10-20", "18:00"), "DURATION:PT1H30M"}, []string{"DUE", "DTEND", "STATUS"}},
+		{"call", []string{"BEGIN:VEVENT", "DTSTART:" + utc("2026-10-21", "09:05")}, []string{"DUE", "DURATION"}},
+		{"report", []string{"BEGIN:VTODO", "DUE;VALUE=DATE:20261022", "STATUS:NEEDS-ACTION"}, []string{"DTSTART", "DURATION"}},
+		{"rent", []string{"BEGIN:VTODO", "DUE;VALUE=DATE:20261101", "RRULE:FREQ=MONTHLY"}, []string{"DTSTART"}},
+	}
+	for _, tt := range tests {
+		lines := comps[tt.uid]
+		have := map[string]bool{}
+		names := map[string]bool{}
+		for _, l := range lines {
+			have[l] = true
+			name, _, _ := splitICalLine(l)
+			names[name] = true
+		}
+		for _, w := range tt.want {
+			if !have[w] {
+				t.Errorf("%s: no %s in %q", tt.uid, w, lines)
+			}
+		}
+		for _, name := range tt.dontWant {
+			if names[name] {
+				t.Errorf("%s: unexpected %s in %q", tt.uid, name, lines)
+			}
+		}
+	}
+
+	back, err := DecodeCalendar(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != len(tasks) {
+		t.Fatalf("decoded %d tasks, want %d", len(back), len(tasks))
+	}
+	for i, task := range back {
+		if task.DueLabel() != tasks[i].DueLabel() || task.Recurring != tasks[i].Recurring {
+			t.Errorf("%s came back due %q (%q), want %q (%q)", task.UUID,
+				task.DueLabel(), task.Recurring, tasks[i].DueLabel(), tasks[i].Recurring)
+		}
+	}
+}
+
+func TestDecodeEventWithEnd(t *testing.T) {
+	// Calendar apps often give an end instead of a duration
+	cal := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:dentist\r\nSUMMARY:Dentist\r\n" +
+		"DTSTART:20261020T140000\r\nDTEND:20261020T144500\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	tasks, err := DecodeCalendar([]byte(cal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].DueLabel() != "2026-10-20 14:00 for 45m" {
+		t.Errorf("decoded %+v, want due 2026-10-20 14:00 for 45m", tasks)
+	}
+}
+
+func TestICalLinesFoldWithin75Octets(t *testing.T) {
+	text := strings.Repeat("Ünïcödé notes ", 20)
+	data := EncodeCalendar([]Task{{UUID: "long", Text: text}})
+	for _, line := range bytes.Split(data, []byte("\r\n")) {
+		if len(line) > 75 {
+			t.Errorf("%d-octet line %q", len(line), line)
+		}
+		if !utf8.Valid(line) {
+			t.Errorf("fold split a character: %q", line)
+		}
+	}
+	tasks, err := DecodeCalendar(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Text != text {
+		t.Errorf("decoded %q, want the summary back", tasks[0].Text)
+	}
+}
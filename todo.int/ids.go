@@ -0,0 +1,117 @@
+package todo
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NextID is the one place display IDs are allocated. It never hands out an
+// ID that's already in the list, whatever order the tasks are in.
+func NextID(tasks []Task) int {
+	max := 0
+	for _, t := range tasks {
+		if t.ID > max {
+			max = t.ID
+		}
+	}
+	return max + 1
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// minUUIDPrefix is the shortest UUID prefix ResolveTask takes, so a short
+// word or a mistyped ID doesn't land on a random task
+const minUUIDPrefix = 4
+
+// taskRefError is ResolveTask's error for input that matches no task. It
+// is ErrTaskNotFound to errors.Is.
+type taskRefError struct{ input string }
+
+func (e taskRefError) Error() string        { return "no task " + e.input }
+func (e taskRefError) Is(target error) bool { return target == ErrTaskNotFound }
+
+// ResolveTask finds the task input refers to and returns its index. input
+// can be a display ID, the exact task text, or a unique UUID prefix of at
+// least minUUIDPrefix hex digits. All-digit input is only ever a display
+// ID.
+func ResolveTask(tasks []Task, input string) (int, error) {
+	if strings.TrimSpace(input) == "" {
+		return -1, errors.New("no task given")
+	}
+	id, err := strconv.Atoi(input)
+	isID := err == nil
+	if isID {
+		for i, t := range tasks {
+			if t.ID == id {
+				return i, nil
+			}
+		}
+	}
+	for i, t := range tasks {
+		if t.Text == input {
+			return i, nil
+		}
+	}
+	if isID || !isUUIDPrefix(input) {
+		return -1, taskRefError{input}
+	}
+
+	prefix := strings.ToLower(input)
+	match := -1
+	for i, t := range tasks {
+		if t.UUID != "" && strings.HasPrefix(t.UUID, prefix) {
+			if match != -1 {
+				return -1, fmt.Errorf("%q matches more than one task", input)
+			}
+			match = i
+		}
+	}
+	if match == -1 {
+		return -1, taskRefError{input}
+	}
+	return match, nil
+}
+
+// isUUIDPrefix reports whether s could be the start of a UUID: hex digits
+// and dashes, with at least minUUIDPrefix digits
+func isUUIDPrefix(s string) bool {
+	digits := 0
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'f':
+			digits++
+		case r != '-':
+			return false
+		}
+	}
+	return digits >= minUUIDPrefix
+}
+
+// RenumberTasks compacts display IDs to 1..n in list order. UUIDs are left
+// alone, so anything referring to a task by UUID keeps working. Returns the
+// old -> new ID mapping for tasks whose ID changed.
+func RenumberTasks(s Store) (map[int]int, error) {
+	changed := map[int]int{}
+	err := Modify(s, "renumber", func(tasks []Task) ([]Task, error) {
+		for i := range tasks {
+			if tasks[i].ID != i+1 {
+				changed[tasks[i].ID] = i + 1
+				tasks[i].ID = i + 1
+			}
+		}
+		return tasks, nil
+	})
+	return changed, err
+}
@@ -0,0 +1,56 @@
+package todo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveTask(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Text: "Buy milk", UUID: "abcd1234-0000-4000-8000-000000000001"},
+		{ID: 2, Text: "Write report", UUID: "abcd5678-0000-4000-8000-000000000002"},
+		{ID: 12, Text: "42", UUID: "1234abcd-0000-4000-8000-000000000003"},
+	}
+	tests := []struct {
+		name     string
+		input    string
+		want     int
+		notFound bool // the error is ErrTaskNotFound
+		wantErr  string
+	}{
+		{name: "display ID", input: "2", want: 1},
+		{name: "two-digit display ID", input: "12", want: 2},
+		{name: "exact text", input: "Write report", want: 1},
+		{name: "text that is a number", input: "42", want: 2},
+		{name: "UUID prefix", input: "abcd1", want: 0},
+		{name: "UUID prefix in upper case", input: "ABCD5", want: 1},
+		{name: "UUID prefix with dash", input: "1234abcd-0", want: 2},
+		{name: "ambiguous UUID prefix", input: "abcd", want: -1, wantErr: `"abcd" matches more than one task`},
+		{name: "prefix too short", input: "abc", want: -1, notFound: true, wantErr: "no task abc"},
+		{name: "digits are never a UUID prefix", input: "1234", want: -1, notFound: true, wantErr: "no task 1234"},
+		{name: "unknown display ID", input: "4", want: -1, notFound: true, wantErr: "no task 4"},
+		{name: "not hex", input: "milk", want: -1, notFound: true, wantErr: "no task milk"},
+		{name: "empty", input: "", want: -1, wantErr: "no task given"},
+		{name: "blank", input: "  ", want: -1, wantErr: "no task given"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveTask(tasks, tt.input)
+			if got != tt.want {
+				t.Errorf("ResolveTask(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ResolveTask(%q) error = %v", tt.input, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ResolveTask(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			if errors.Is(err, ErrTaskNotFound) != tt.notFound {
+				t.Errorf("errors.Is(%v, ErrTaskNotFound) = %v, want %v", err, !tt.notFound, tt.notFound)
+			}
+		})
+	}
+}
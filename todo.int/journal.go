@@ -0,0 +1,238 @@
+package todo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// EventType names what happened to a task in the journal
+type EventType string
+
+const (
+	TaskAdded         EventType = "TaskAdded"
+	TaskCompleted     EventType = "TaskCompleted"
+	TaskReopened      EventType = "TaskReopened"
+	TextEdited        EventType = "TextEdited"
+	DueDateChanged    EventType = "DueDateChanged"
+	TagsChanged       EventType = "TagsChanged"
+	PriorityChanged   EventType = "PriorityChanged"
+	RecurrenceChanged EventType = "RecurrenceChanged"
+	IDChanged         EventType = "IDChanged"
+	TaskUpdated       EventType = "TaskUpdated"
+	TaskDeleted       EventType = "TaskDeleted"
+)
+
+// Event is one line of the journal. Task is the task as it stood right
+// after the event (right before, for TaskDeleted), which is all replay
+// needs. From and To are the old and new values for field changes.
+type Event struct {
+	Type  EventType `json:"type"`
+	Time  time.Time `json:"time"`
+	Op    string    `json:"op"`
+	UUID  string    `json:"uuid"`
+	Index int       `json:"index,omitempty"`
+	From  string    `json:"from,omitempty"`
+	To    string    `json:"to,omitempty"`
+	Task  Task      `json:"task"`
+}
+
+// String renders the event for `todo log`
+func (e Event) String() string {
+	line := fmt.Sprintf("%s  %-17s %d %s", e.Time.Local().Format("2006-01-02 15:04"), e.Type, e.Task.ID, e.Task.Text)
+	if e.From != "" || e.To != "" {
+		line += fmt.Sprintf(": %s → %s", orDash(e.From), orDash(e.To))
+	}
+	if e.Op != "" {
+		line += fmt.Sprintf("  (%s)", e.Op)
+	}
+	return line
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Journal is an append-only log of typed task events, one JSON object per
+// line. Replaying it from the start rebuilds the task list.
+type Journal struct {
+	Path string
+	// Encryption, when set, seals each line on its own so the journal
+	// stays append-only
+	Encryption *Encryption
+}
+
+// JournalFor returns the journal that belongs to the task file at dataPath
+func JournalFor(dataPath string) *Journal {
+	return &Journal{Path: SidecarPath(dataPath, "journal.jsonl")}
+}
+
+// Record appends the events making up a change. The first time anything is
+// recorded, the tasks that already existed are written as TaskAdded events
+// so the journal can rebuild the full list.
+func (j *Journal) Record(c Change) error {
+	var events []Event
+	if _, err := os.Stat(j.Path); errors.Is(err, os.ErrNotExist) {
+		for i, t := range c.Before {
+			events = append(events, Event{Type: TaskAdded, Time: c.Time, Op: "import", UUID: t.UUID, Index: i, Task: t})
+		}
+	}
+	for _, d := range c.Diffs() {
+		events = append(events, eventsForDiff(c, d)...)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(j.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if line, err = j.Encryption.seal(line); err != nil {
+			return err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func eventsForDiff(c Change, d TaskDiff) []Event {
+	if d.Before == nil {
+		return []Event{{Type: TaskAdded, Time: c.Time, Op: c.Op, UUID: d.After.UUID, Index: d.Index, Task: *d.After}}
+	}
+	if d.After == nil {
+		return []Event{{Type: TaskDeleted, Time: c.Time, Op: c.Op, UUID: d.Before.UUID, Task: *d.Before}}
+	}
+
+	b, a := d.Before, d.After
+	var events []Event
+	add := func(t EventType, from, to string) {
+		events = append(events, Event{Type: t, Time: c.Time, Op: c.Op, UUID: a.UUID, From: from, To: to, Task: *a})
+	}
+	if b.ID != a.ID {
+		add(IDChanged, fmt.Sprint(b.ID), fmt.Sprint(a.ID))
+	}
+	if b.Text != a.Text {
+		add(TextEdited, b.Text, a.Text)
+	}
+	if !b.Completed && a.Completed {
+		add(TaskCompleted, "", "")
+	}
+	if b.Completed && !a.Completed {
+		add(TaskReopened, "", "")
+	}
+	if b.DueLabel() != a.DueLabel() {
+		add(DueDateChanged, b.DueLabel(), a.DueLabel())
+	}
+	if strings.Join(b.Tags, ",") != strings.Join(a.Tags, ",") {
+		add(TagsChanged, strings.Join(b.Tags, ","), strings.Join(a.Tags, ","))
+	}
+	if b.Priority != a.Priority {
+		add(PriorityChanged, b.Priority, a.Priority)
+	}
+	if b.Recurring != a.Recurring {
+		add(RecurrenceChanged, b.Recurring, a.Recurring)
+	}
+	if len(events) == 0 && !reflect.DeepEqual(*b, *a) {
+		add(TaskUpdated, "", "")
+	}
+	return events
+}
+
+// Events reads the whole journal in order
+func (j *Journal) Events() ([]Event, error) {
+	f, err := os.Open(j.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		data, err := j.Encryption.open(sc.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", j.Path, line, err)
+		}
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", j.Path, line, err)
+		}
+		events = append(events, e)
+	}
+	return events, sc.Err()
+}
+
+// Replay rebuilds the task list from the journal
+func (j *Journal) Replay() ([]Task, error) {
+	events, err := j.Events()
+	if err != nil {
+		return nil, err
+	}
+	return ReplayEvents(events), nil
+}
+
+// ReplayEvents applies events in order to an empty list
+func ReplayEvents(events []Event) []Task {
+	tasks := []Task{}
+	for _, e := range events {
+		switch e.Type {
+		case TaskAdded:
+			tasks = removeByUUID(tasks, e.UUID)
+			i := e.Index
+			if i > len(tasks) {
+				i = len(tasks)
+			}
+			tasks = append(tasks[:i], append([]Task{e.Task}, tasks[i:]...)...)
+		case TaskDeleted:
+			tasks = removeByUUID(tasks, e.UUID)
+		default:
+			for i := range tasks {
+				if tasks[i].UUID == e.UUID {
+					tasks[i] = e.Task
+					break
+				}
+			}
+		}
+	}
+	return tasks
+}
+
+// TaskEvents filters events down to the ones about a single task
+func TaskEvents(events []Event, uuid string) []Event {
+	var out []Event
+	for _, e := range events {
+		if e.UUID == uuid {
+			out = append(out, e)
+		}
+	}
+	return out
+}
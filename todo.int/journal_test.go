@@ -0,0 +1,69 @@
+package todo
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJournalReplay(t *testing.T) {
+	// A task from before the journal was turned on
+	base := NewMemoryStore(Task{ID: 1, UUID: "aaaa0000-0000-4000-8000-000000000001", Text: "Old chore"})
+	j := JournalFor(filepath.Join(t.TempDir(), "tasks.json"))
+	s := Track(base, j)
+
+	steps := []struct {
+		do   func() error
+		want []EventType
+	}{
+		{func() error { return AddTaskWithDueDate(s, "Buy milk", "") }, []EventType{TaskAdded, TaskAdded}},
+		{func() error { return AddTaskWithDueDate(s, "Call mom", "") }, []EventType{TaskAdded}},
+		{func() error { return MarkTaskDone(s, "2") }, []EventType{TaskCompleted}},
+		{func() error { return EditTaskText(s, "3", "Call dad") }, []EventType{TextEdited}},
+		{func() error { return SetTags(s, 3, []string{"family"}) }, []EventType{TagsChanged}},
+		{func() error { return SetDueDate(s, "3", "2026-10-20 @ 18:00 for 30m") }, []EventType{DueDateChanged}},
+		{func() error { return DeleteTask(s, "1") }, []EventType{TaskDeleted}},
+	}
+	var want []EventType
+	for i, st := range steps {
+		if err := st.do(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		want = append(want, st.want...)
+
+		events, err := j.Events()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []EventType
+		for _, e := range events {
+			got = append(got, e.Type)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("step %d: events %v, want %v", i, got, want)
+		}
+
+		replayed, err := j.Replay()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks, _ := s.Load()
+		if len(replayed) != len(tasks) {
+			t.Fatalf("step %d: replayed %d tasks, want %d", i, len(replayed), len(tasks))
+		}
+		for k := range tasks {
+			if !sameTask(replayed[k], tasks[k]) {
+				t.Errorf("step %d: replayed\n  %+v\nwant\n  %+v", i, replayed[k], tasks[k])
+			}
+		}
+	}
+
+	events, _ := j.Events()
+	due := events[len(events)-2]
+	if due.From != "" || due.To != "2026-10-20 18:00 for 30m" || due.Op != "due" {
+		t.Errorf("due event = %+v", due)
+	}
+	if calls := TaskEvents(events, due.UUID); len(calls) != 4 {
+		t.Errorf("%d events for Call mom, want 4", len(calls))
+	}
+}
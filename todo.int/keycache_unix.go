@@ -0,0 +1,37 @@
+//go:build unix
+
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// noFollow makes opening a symlink fail, so a link planted in the key
+// cache can't redirect a key somewhere else
+const noFollow = syscall.O_NOFOLLOW
+
+// privateDir creates dir if needed and checks that it is a real directory,
+// owned by the current user and closed to everyone else. A directory in a
+// shared place like /tmp could have been created by another user first.
+func privateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s belongs to another user", dir)
+	}
+	if fi.Mode().Perm() != 0700 {
+		return fmt.Errorf("%s is open to other users (mode %o)", dir, fi.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package todo
+
+import "os"
+
+// noFollow is 0: Windows has no O_NOFOLLOW, and the key cache lives in
+// the user's own temp directory there
+const noFollow = 0
+
+// privateDir creates dir if needed. The user's temp directory on Windows
+// is already private.
+func privateDir(dir string) error {
+	return os.MkdirAll(dir, 0700)
+}
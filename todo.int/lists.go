@@ -0,0 +1,96 @@
+package todo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultList is the name of the list kept in the task file itself
+const DefaultList = "default"
+
+var listName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ListPath returns the task file for the named list. The default list is
+// dataPath; every other list lives in lists/<name> next to it, with the
+// same extension, and gets its own history, trash and journal there.
+func ListPath(dataPath, name string) (string, error) {
+	if name == "" || name == DefaultList {
+		return dataPath, nil
+	}
+	if !listName.MatchString(name) {
+		return "", fmt.Errorf("invalid list name %q: use letters, digits, - and _", name)
+	}
+	dir := filepath.Join(filepath.Dir(dataPath), "lists")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+filepath.Ext(dataPath)), nil
+}
+
+// ListNames returns the default list followed by every named list found
+// next to dataPath, sorted by name
+func ListNames(dataPath string) ([]string, error) {
+	ext := filepath.Ext(dataPath)
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(dataPath), "lists"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ext)
+		// Sidecars (work.json.history.json, ...) don't match the name pattern
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ext || !listName.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultList}, names...), nil
+}
+
+// MoveTask moves the task input refers to from one list to another. It
+// keeps its UUID but gets the next free ID in the destination. The task is
+// added to the destination before it is removed from the source, so a
+// failure part way leaves a duplicate rather than losing it.
+func MoveTask(from, to Store, input string) (Task, error) {
+	tasks, err := from.Load()
+	if err != nil {
+		return Task{}, err
+	}
+	i, err := ResolveTask(tasks, input)
+	if err != nil {
+		return Task{}, err
+	}
+	task := tasks[i]
+	moved := task
+	if moved.UUID == "" {
+		moved.UUID = newUUID()
+	}
+
+	err = Modify(to, "move", func(dest []Task) ([]Task, error) {
+		for _, t := range dest {
+			if t.UUID == moved.UUID {
+				return nil, fmt.Errorf("task %d is already in that list", t.ID)
+			}
+		}
+		moved.ID = NextID(dest)
+		return append(dest, moved), nil
+	})
+	if err != nil {
+		return Task{}, err
+	}
+
+	err = Modify(from, "move", func(src []Task) ([]Task, error) {
+		for i, t := range src {
+			if (task.UUID != "" && t.UUID == task.UUID) || (task.UUID == "" && t.ID == task.ID) {
+				return append(src[:i], src[i+1:]...), nil
+			}
+		}
+		return src, nil
+	})
+	return moved, err
+}
@@ -0,0 +1,279 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Markdown checklists, as GitHub renders them:
+//
+//	## Release
+//	- [ ] Write changelog #docs !high due:2026-10-20
+//	  - [x] Collect merged PRs
+//
+// Tags are #tag or @context, priority !high, !medium or !low (or pri: as
+// in todo.txt for any other), and due:, for: and rec: hold the due date
+// (with THH:MM for a time), duration and recurrence. Items nested under an item are its subtasks (Task.Parent),
+// and headings become tags, or lists with MarkdownSection.
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBullet   = regexp.MustCompile(`^([ \t]*)(?:[-*+]|\d+[.)])\s+(.*)$`)
+	mdCheckbox = regexp.MustCompile(`^\[([ xX])\]\s*(.*)$`)
+	mdTag      = regexp.MustCompile(`^[#@][\p{L}][\p{L}\p{N}_/-]*$`)
+	mdClock    = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+	slugStrip  = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// MarkdownSection is the checklist under one heading. Heading is "" for
+// the items before the first heading.
+type MarkdownSection struct {
+	Heading string
+	Tasks   []Task
+}
+
+// Slug is the heading as a tag or list name: "Next Sprint 🚀" is
+// "next-sprint"
+func (sec MarkdownSection) Slug() string {
+	slug := strings.Join(strings.Fields(strings.ToLower(sec.Heading)), "-")
+	return strings.Trim(slugStrip.ReplaceAllString(slug, ""), "-")
+}
+
+// EncodeMarkdown writes tasks as a checklist, subtasks nested under their
+// parents
+func EncodeMarkdown(tasks []Task) ([]byte, error) {
+	return EncodeMarkdownSections([]MarkdownSection{{Tasks: tasks}})
+}
+
+// EncodeMarkdownSections writes a checklist per section, each under its
+// heading
+func EncodeMarkdownSections(sections []MarkdownSection) ([]byte, error) {
+	var b bytes.Buffer
+	for i, sec := range sections {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if sec.Heading != "" {
+			b.WriteString("## " + sec.Heading + "\n\n")
+		}
+		children := map[string][]Task{}
+		known := map[string]bool{}
+		for _, t := range sec.Tasks {
+			known[t.UUID] = true
+		}
+		var top []Task
+		for _, t := range sec.Tasks {
+			if t.Parent != "" && known[t.Parent] && t.Parent != t.UUID {
+				children[t.Parent] = append(children[t.Parent], t)
+			} else {
+				top = append(top, t)
+			}
+		}
+		written := map[string]bool{}
+		var write func(t Task, depth int)
+		write = func(t Task, depth int) {
+			if written[t.UUID] {
+				return // a cycle of parents
+			}
+			written[t.UUID] = true
+			b.WriteString(strings.Repeat("  ", depth) + markdownLine(t) + "\n")
+			for _, c := range children[t.UUID] {
+				write(c, depth+1)
+			}
+		}
+		for _, t := range top {
+			write(t, 0)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func markdownLine(t Task) string {
+	box := "- [ ] "
+	if t.Completed {
+		box = "- [x] "
+	}
+	parts := []string{strings.Join(strings.Fields(t.Text), " ")}
+	for _, tag := range t.Tags {
+		tag = strings.Join(strings.Fields(tag), "_")
+		if tag != "" && tag[0] != '#' && tag[0] != '@' {
+			tag = "#" + tag
+		}
+		parts = append(parts, tag)
+	}
+	if p := todoTxtPriorityName(t.Priority); p != "" {
+		if name := strings.ToLower(p); name == "high" || name == "medium" || name == "low" {
+			parts = append(parts, "!"+name)
+		} else {
+			parts = append(parts, "pri:"+strings.Join(strings.Fields(p), "_"))
+		}
+	}
+	if t.DueDate != "" {
+		due := "due:" + t.DueDate
+		if t.DueTime != "" {
+			due += "T" + t.DueTime
+		}
+		parts = append(parts, due)
+	}
+	if t.Duration != "" {
+		parts = append(parts, "for:"+t.Duration)
+	}
+	if t.Recurring != "" {
+		parts = append(parts, "rec:"+todoTxtRecurrence(t.Recurring))
+	}
+	return box + strings.Join(parts, " ")
+}
+
+// DecodeMarkdown reads the checklist items in a Markdown file, tagging each
+// with the heading it is under
+func DecodeMarkdown(data []byte) ([]Task, error) {
+	sections, err := DecodeMarkdownSections(data)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for _, sec := range sections {
+		slug := sec.Slug()
+		for _, t := range sec.Tasks {
+			if slug != "" && !hasTag(t.Tags, slug) {
+				t.Tags = append(t.Tags, slug)
+			}
+			t.ID = len(tasks) + 1
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+// DecodeMarkdownSections reads the checklist items in a Markdown file,
+// grouped by the heading they are under. Bullets without a checkbox count
+// only when nested under an item, as its subtasks; other text is skipped.
+func DecodeMarkdownSections(data []byte) ([]MarkdownSection, error) {
+	sections := []MarkdownSection{{}}
+	type open struct {
+		indent int
+		uuid   string
+	}
+	var stack []open
+	inFence := false
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			sections = append(sections, MarkdownSection{Heading: m[2]})
+			stack = nil
+			continue
+		}
+		m := mdBullet.FindStringSubmatch(line)
+		if m == nil {
+			if line != "" && line[0] != ' ' && line[0] != '\t' {
+				stack = nil // a paragraph ends the list
+			}
+			continue
+		}
+
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		box := mdCheckbox.FindStringSubmatch(m[2])
+		if box == nil && len(stack) == 0 {
+			continue
+		}
+		t := Task{UUID: newUUID()}
+		text := m[2]
+		if box != nil {
+			t.Completed = box[1] != " "
+			text = box[2]
+		}
+		parseMarkdownTokens(&t, text)
+		if t.Text == "" {
+			continue
+		}
+		if len(stack) > 0 {
+			t.Parent = stack[len(stack)-1].uuid
+		}
+		stack = append(stack, open{indent, t.UUID})
+		sec := &sections[len(sections)-1]
+		sec.Tasks = append(sec.Tasks, t)
+	}
+
+	var out []MarkdownSection
+	for _, sec := range sections {
+		if len(sec.Tasks) > 0 {
+			out = append(out, sec)
+		}
+	}
+	return out, sc.Err()
+}
+
+// parseMarkdownTokens splits an item's text into the task text and the
+// tags, priority and due tokens in it
+func parseMarkdownTokens(t *Task, text string) {
+	var words []string
+	for _, w := range strings.Fields(text) {
+		key, value, isKey := strings.Cut(w, ":")
+		switch {
+		case mdTag.MatchString(w):
+			tag := w
+			if w[0] == '#' {
+				tag = w[1:]
+			}
+			t.Tags = append(t.Tags, tag)
+		case len(w) > 1 && w[0] == '!' && markdownPriority(w[1:]) != "":
+			t.Priority = markdownPriority(w[1:])
+		case isKey && key == "due" && value != "":
+			input := value
+			if i := strings.LastIndex(value, "T"); i > 0 && mdClock.MatchString(value[i+1:]) {
+				input = value[:i] + " @ " + value[i+1:]
+			}
+			var due Task
+			if due.SetDue(input) != nil {
+				words = append(words, w)
+				continue
+			}
+			t.DueDate, t.DueTime = due.DueDate, due.DueTime
+		case isKey && key == "for" && value != "":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				words = append(words, w)
+				continue
+			}
+			t.Duration = shortDuration(d)
+		case isKey && key == "rec" && value != "":
+			t.Recurring = recurrenceFromTodoTxt(value)
+		case isKey && key == "pri" && value != "":
+			t.Priority = priorityFromTodoTxt(value)
+		default:
+			words = append(words, w)
+		}
+	}
+	t.Text = strings.Join(words, " ")
+	if t.DueTime == "" {
+		t.Duration = ""
+	}
+}
+
+func markdownPriority(p string) string {
+	switch strings.ToLower(p) {
+	case "high", "h":
+		return "high"
+	case "medium", "med", "m":
+		return "medium"
+	case "low", "l":
+		return "low"
+	}
+	return ""
+}
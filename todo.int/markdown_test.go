@@ -0,0 +1,120 @@
+package todo
+
+import "testing"
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{
+			name: "flat checklist",
+			doc: "- [ ] Write changelog #docs !high due:2026-10-20\n" +
+				"- [x] Tag release\n",
+		},
+		{
+			name: "due time, duration and recurrence",
+			doc:  "- [ ] Stand-up @team due:2026-10-20T09:30 for:15m rec:1d\n",
+		},
+		{
+			name: "other priorities",
+			doc: "- [ ] File taxes pri:D\n" +
+				"- [ ] Sort photos pri:some_day\n",
+		},
+		{
+			name: "subtasks",
+			doc: "- [ ] Release\n" +
+				"  - [x] Collect merged PRs\n" +
+				"  - [ ] Write notes\n" +
+				"    - [ ] Ask for screenshots\n" +
+				"- [ ] Celebrate\n",
+		},
+		{
+			name: "sections",
+			doc: "- [ ] Loose end\n" +
+				"\n" +
+				"## Next Sprint\n" +
+				"\n" +
+				"- [ ] Plan\n" +
+				"  - [ ] Estimate\n" +
+				"\n" +
+				"## Later\n" +
+				"\n" +
+				"- [ ] Rewrite it in Rust !low\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections, err := DecodeMarkdownSections([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := EncodeMarkdownSections(sections)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.doc {
+				t.Errorf("encoded as\n%s\nwant\n%s", out, tt.doc)
+			}
+		})
+	}
+}
+
+func TestMarkdownPriorityLetters(t *testing.T) {
+	// Letters from todo.txt or a CSV: A to C are the named priorities
+	tasks := []Task{
+		{UUID: "mom", Text: "Call mom", Priority: "A"},
+		{UUID: "milk", Text: "Buy milk", Priority: "C"},
+		{UUID: "nap", Text: "Nap", Priority: "Z"},
+	}
+	out, err := EncodeMarkdown(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- [ ] Call mom !high\n- [ ] Buy milk !low\n- [ ] Nap pri:Z\n"
+	if string(out) != want {
+		t.Errorf("encoded as\n%s\nwant\n%s", out, want)
+	}
+	back, err := DecodeMarkdown(out)
+	if err != nil || len(back) != 3 {
+		t.Fatalf("decoded %+v, %v", back, err)
+	}
+	for i, want := range []string{"high", "low", "Z"} {
+		if back[i].Priority != want {
+			t.Errorf("%s: priority %q, want %q", back[i].Text, back[i].Priority, want)
+		}
+	}
+}
+
+func TestDecodeMarkdown(t *testing.T) {
+	doc := "## Next Sprint 🚀\n\n" +
+		"- [ ] Write notes #docs !medium due:2026-10-20T14:00 for:1h\n" +
+		"  - [x] Ask for screenshots\n" +
+		"  - plain bullet subtask\n" +
+		"\n" +
+		"Not a task.\n" +
+		"- not a task either\n"
+	tasks, err := DecodeMarkdown([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 3 {
+		t.Fatalf("decoded %d tasks, want 3: %+v", len(tasks), tasks)
+	}
+	notes := tasks[0]
+	if notes.Text != "Write notes" || notes.Priority != "medium" ||
+		notes.DueDate != "2026-10-20" || notes.DueTime != "14:00" || notes.Duration != "1h" {
+		t.Errorf("first task = %+v", notes)
+	}
+	if len(notes.Tags) != 2 || notes.Tags[0] != "docs" || notes.Tags[1] != "next-sprint" {
+		t.Errorf("tags = %q, want [docs next-sprint]", notes.Tags)
+	}
+	for _, sub := range tasks[1:] {
+		if sub.Parent != notes.UUID {
+			t.Errorf("%q has parent %q, want %q", sub.Text, sub.Parent, notes.UUID)
+		}
+	}
+	if !tasks[1].Completed || tasks[2].Completed {
+		t.Errorf("completed = %v, %v; want true, false", tasks[1].Completed, tasks[2].Completed)
+	}
+}
@@ -0,0 +1,77 @@
+package todo
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// MemoryStore keeps tasks in memory. Useful for embedding and for tools
+// that bring their own persistence.
+type MemoryStore struct {
+	mu    sync.Mutex
+	cycle sync.Mutex
+	tasks []Task
+}
+
+// NewMemoryStore returns a store seeded with a copy of tasks
+func NewMemoryStore(tasks ...Task) *MemoryStore {
+	return &MemoryStore{tasks: cloneTasks(tasks)}
+}
+
+// Load returns a copy of the stored tasks
+func (s *MemoryStore) Load() ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneTasks(s.tasks), nil
+}
+
+// Save replaces the stored tasks
+func (s *MemoryStore) Save(tasks []Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = cloneTasks(tasks)
+	return nil
+}
+
+// Get looks up a single task by ID
+func (s *MemoryStore) Get(id int) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return findByID(s.tasks, id)
+}
+
+// Update replaces a single task
+func (s *MemoryStore) Update(task Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return replaceByID(s.tasks, task)
+}
+
+// Lock serialises load-modify-save cycles within the process
+func (s *MemoryStore) Lock() (func() error, error) {
+	s.cycle.Lock()
+	return func() error {
+		s.cycle.Unlock()
+		return nil
+	}, nil
+}
+
+func cloneTasks(tasks []Task) []Task {
+	out := make([]Task, len(tasks))
+	for i, t := range tasks {
+		t.Tags = append([]string(nil), t.Tags...)
+		t.Notes = append([]Note(nil), t.Notes...)
+		if t.Modified != nil {
+			t.Modified = copyModified(t.Modified)
+		}
+		if t.Extra != nil {
+			extra := make(map[string]json.RawMessage, len(t.Extra))
+			for k, v := range t.Extra {
+				extra[k] = v
+			}
+			t.Extra = extra
+		}
+		out[i] = t
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the task file format written by this build. Bump it and
+// add a step to fileMigrations whenever stored fields change shape.
+const SchemaVersion = 2
+
+// taskFile is the on-disk layout of tasks.json
+type taskFile struct {
+	Version int    `json:"version"`
+	Tasks   []Task `json:"tasks"`
+}
+
+// fileMigrations upgrade raw task objects from version i to i+1.
+// Version 0 is the original bare-array file, which needs no field changes.
+var fileMigrations = map[int]func(tasks []map[string]json.RawMessage) error{
+	0: func([]map[string]json.RawMessage) error { return nil },
+	// 1 -> 2: give every task a stable UUID
+	1: func(tasks []map[string]json.RawMessage) error {
+		for _, t := range tasks {
+			if _, ok := t["uuid"]; !ok {
+				id, _ := json.Marshal(newUUID())
+				t["uuid"] = id
+			}
+		}
+		return nil
+	},
+}
+
+// decodeTaskFile reads either a versioned file or a legacy bare array,
+// running any migrations needed to bring it up to SchemaVersion.
+// upgraded reports whether any migration ran.
+func decodeTaskFile(data []byte) (tasks []Task, upgraded bool, err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return []Task{}, false, nil
+	}
+
+	var raw []map[string]json.RawMessage
+	version := 0
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, false, err
+		}
+	} else {
+		var f struct {
+			Version int                          `json:"version"`
+			Tasks   []map[string]json.RawMessage `json:"tasks"`
+		}
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, false, err
+		}
+		version, raw = f.Version, f.Tasks
+	}
+
+	if version > SchemaVersion {
+		return nil, false, fmt.Errorf("task file is schema version %d, this build only understands up to %d", version, SchemaVersion)
+	}
+	upgraded = version < SchemaVersion
+	for ; version < SchemaVersion; version++ {
+		migrate, ok := fileMigrations[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no migration from schema version %d", version)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, false, fmt.Errorf("migrating from schema version %d: %w", version, err)
+		}
+	}
+
+	current, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	tasks = []Task{}
+	err = json.Unmarshal(current, &tasks)
+	return tasks, upgraded, err
+}
+
+func encodeTaskFile(tasks []Task) ([]byte, error) {
+	if tasks == nil {
+		tasks = []Task{}
+	}
+	return json.MarshalIndent(taskFile{Version: SchemaVersion, Tasks: tasks}, "", "  ")
+}
+
+// taskAlias has Task's fields without its JSON methods
+type taskAlias Task
+
+// knownTaskFields are the JSON keys Task maps to struct fields
+var knownTaskFields = func() map[string]bool {
+	known := map[string]bool{}
+	t := reflect.TypeOf(Task{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+	return known
+}()
+
+// UnmarshalJSON decodes a task, keeping keys Task has no field for in Extra
+func (t *Task) UnmarshalJSON(data []byte) error {
+	var alias taskAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for key := range all {
+		if knownTaskFields[key] {
+			delete(all, key)
+		}
+	}
+	*t = Task(alias)
+	if len(all) > 0 {
+		t.Extra = all
+	}
+	return nil
+}
+
+// MarshalJSON encodes a task, writing Extra keys back after the known fields
+func (t Task) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(taskAlias(t))
+	if err != nil || len(t.Extra) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(t.Extra))
+	for key := range t.Extra {
+		if !knownTaskFields[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, key := range keys {
+		name, _ := json.Marshal(key)
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(t.Extra[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
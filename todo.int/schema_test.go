@@ -0,0 +1,123 @@
+package todo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTaskKeepsUnknownFields(t *testing.T) {
+	in := `{"id":1,"text":"Buy milk","completed":false,"estimate":"3h","owner":{"name":"sam"},"links":[1,2]}`
+	var task Task
+	if err := json.Unmarshal([]byte(in), &task); err != nil {
+		t.Fatal(err)
+	}
+	if len(task.Extra) != 3 || string(task.Extra["owner"]) != `{"name":"sam"}` {
+		t.Fatalf("extra = %s, want the three unknown keys only", task.Extra)
+	}
+
+	task.Text = "Buy oat milk"
+	out, err := json.Marshal(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":1,"text":"Buy oat milk","completed":false,"estimate":"3h","links":[1,2],"owner":{"name":"sam"}}`
+	if string(out) != want {
+		t.Errorf("encoded %s\nwant %s", out, want)
+	}
+
+	// An Extra key that shadows a field isn't written twice
+	task.Extra["text"] = json.RawMessage(`"stale"`)
+	out, _ = json.Marshal(task)
+	if strings.Count(string(out), `"text"`) != 1 {
+		t.Errorf("text written twice: %s", out)
+	}
+}
+
+func TestJSONStoreKeepsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	file := `{"version": 2, "tasks": [
+		{"id": 1, "uuid": "aaaa0000-0000-4000-8000-000000000001", "text": "Buy milk", "completed": false, "estimate": "3h"},
+		{"id": 2, "uuid": "aaaa0000-0000-4000-8000-000000000002", "text": "Call mom", "completed": false, "x-sync": {"rev": 7}}
+	]}`
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewJSONStore(path)
+	if err := MarkTaskDone(s, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := EditTaskText(s, "2", "Call dad"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"estimate": "3h"`, `"x-sync": {`, `"rev": 7`, `"Call dad"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("file lost %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestDecodeTaskFile(t *testing.T) {
+	const kept = "aaaa0000-0000-4000-8000-000000000002"
+	tests := []struct {
+		name         string
+		data         string
+		wantTexts    []string
+		wantUUIDs    []string // "" for one made up in the upgrade
+		wantUpgraded bool
+		wantErr      string
+	}{
+		{name: "empty", data: "  \n"},
+		{
+			name:      "bare array",
+			data:      `[{"id":1,"text":"Buy milk","completed":false,"estimate":"3h"}]`,
+			wantTexts: []string{"Buy milk"}, wantUUIDs: []string{""}, wantUpgraded: true,
+		},
+		{
+			name:      "version 1",
+			data:      `{"version":1,"tasks":[{"id":1,"text":"Buy milk","completed":false,"estimate":"3h"},{"id":2,"uuid":"` + kept + `","text":"Call mom","completed":false}]}`,
+			wantTexts: []string{"Buy milk", "Call mom"}, wantUUIDs: []string{"", kept}, wantUpgraded: true,
+		},
+		{
+			name:      "current",
+			data:      `{"version":2,"tasks":[{"id":1,"uuid":"` + kept + `","text":"Buy milk","completed":false,"estimate":"3h"}]}`,
+			wantTexts: []string{"Buy milk"}, wantUUIDs: []string{kept},
+		},
+		{name: "newer", data: `{"version":3,"tasks":[]}`, wantErr: "schema version 3"},
+		{name: "broken", data: `{"version":2,"tasks":[`, wantErr: "unexpected end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, upgraded, err := decodeTaskFile([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if upgraded != tt.wantUpgraded {
+				t.Errorf("upgraded = %v, want %v", upgraded, tt.wantUpgraded)
+			}
+			if len(tasks) != len(tt.wantTexts) {
+				t.Fatalf("decoded %d tasks, want %d", len(tasks), len(tt.wantTexts))
+			}
+			for i, task := range tasks {
+				if task.Text != tt.wantTexts[i] || task.UUID == "" || tt.wantUUIDs[i] != "" && task.UUID != tt.wantUUIDs[i] {
+					t.Errorf("task %d = %q (uuid %q), want %q (uuid %q)", i, task.Text, task.UUID, tt.wantTexts[i], tt.wantUUIDs[i])
+				}
+			}
+			if len(tasks) > 0 && string(tasks[0].Extra["estimate"]) != `"3h"` {
+				t.Errorf("unknown field lost: %s", tasks[0].Extra)
+			}
+		})
+	}
+}
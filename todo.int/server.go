@@ -0,0 +1,369 @@
+package todo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errStale is returned when If-Match doesn't match the stored task
+var errStale = errors.New("task was changed since it was fetched")
+
+// Server exposes a store as a JSON REST API:
+//
+//	GET    /tasks                list, filtered like todo list (?done, ?pending, ?today,
+//	                             ?overdue, ?tag=, ?priority=, ?as_of=, ?archived; the
+//	                             switches take an optional true/false, e.g. ?done=false)
+//	POST   /tasks                create {"text", "due", "tags", "priority", "recurring"}
+//	GET    /tasks/{id}           one task; id is a display ID or UUID prefix
+//	PATCH  /tasks/{id}           update any of the fields above, or "completed"
+//	POST   /tasks/{id}/complete  mark done
+//	DELETE /tasks/{id}           delete (into the trash, if the store keeps one)
+//
+// Responses carry an ETag. Send it back in If-Match when changing a task
+// and the change is refused with 412 if someone else changed it first;
+// If-None-Match on GET gives 304 when nothing changed.
+type Server struct {
+	Store Store
+	// Journal, when set, answers ?as_of= queries
+	Journal *Journal
+	// Archive, when set, answers ?archived queries
+	Archive *Archive
+
+	mu sync.Mutex
+}
+
+// NewServer returns a server for the given store
+func NewServer(s Store) *Server {
+	return &Server{Store: s}
+}
+
+// ServeHTTP routes a request. Requests are handled one at a time.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "tasks" || len(parts) > 3 || len(parts) == 3 && parts[2] != "complete" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		srv.list(w, r)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		srv.create(w, r)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		srv.get(w, r, parts[1])
+	case len(parts) == 2 && r.Method == http.MethodPatch:
+		srv.update(w, r, parts[1])
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		srv.delete(w, r, parts[1])
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		srv.change(w, r, parts[1], "done", func(t *Task) error {
+			t.SetCompleted(true)
+			return nil
+		})
+	default:
+		// PUT isn't offered: a task has fields the API can't set, which
+		// replacing it would wipe
+		w.Header().Set("Allow", allowed[len(parts)])
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed here", r.Method))
+	}
+}
+
+// allowed lists the methods for each depth of path, for the Allow header
+var allowed = map[int]string{1: "GET, POST", 2: "GET, PATCH, DELETE", 3: "POST"}
+
+func (srv *Server) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := ListFilterOptions{
+		Tag:      q.Get("tag"),
+		Priority: strings.ToLower(q.Get("priority")),
+	}
+	var archived bool
+	var err error
+	for name, on := range map[string]*bool{
+		"done": &filter.ShowDone, "pending": &filter.ShowPending, "today": &filter.TodayOnly,
+		"overdue": &filter.OverdueOnly, "archived": &archived,
+	} {
+		if *on, err = querySwitch(q, name); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var tasks []Task
+	if asOf := q.Get("as_of"); asOf != "" {
+		if srv.Journal == nil {
+			writeError(w, http.StatusBadRequest, errors.New("as_of needs the event journal"))
+			return
+		}
+		at, err := EndOfDay(asOf)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if tasks, err = srv.Journal.StateAt(at); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		tasks = FilterTasks(tasks, filter)
+	} else if tasks, err = QueryTasks(srv.Store, filter); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if archived && srv.Archive != nil {
+		old, err := srv.Archive.Tasks()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		tasks = append(tasks, FilterTasks(old, filter)...)
+	}
+	writeJSON(w, r, http.StatusOK, tasks)
+}
+
+// querySwitch reads an on/off query parameter: ?done and ?done=true are
+// on, ?done=false and leaving it out are off
+func querySwitch(q url.Values, name string) (bool, error) {
+	if !q.Has(name) {
+		return false, nil
+	}
+	v := q.Get(name)
+	if v == "" {
+		return true, nil
+	}
+	on, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: want true or false, not %q", name, v)
+	}
+	return on, nil
+}
+
+func (srv *Server) get(w http.ResponseWriter, r *http.Request, ref string) {
+	tasks, err := srv.Store.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	i, err := ResolveTask(tasks, ref)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, tasks[i])
+}
+
+// taskInput is the body of a create or update. Fields left out are left
+// alone.
+type taskInput struct {
+	Text      *string   `json:"text"`
+	Due       *string   `json:"due"`
+	Completed *bool     `json:"completed"`
+	Tags      *[]string `json:"tags"`
+	Priority  *string   `json:"priority"`
+	Recurring *string   `json:"recurring"`
+}
+
+// apply copies the given fields onto t, parsing due dates like the CLI
+func (in taskInput) apply(t *Task) error {
+	if in.Text != nil {
+		if strings.TrimSpace(*in.Text) == "" {
+			return errors.New("text can't be empty")
+		}
+		t.Text = strings.TrimSpace(*in.Text)
+	}
+	if in.Due != nil {
+		if err := t.SetDue(*in.Due); err != nil {
+			return err
+		}
+	}
+	if in.Completed != nil {
+		t.SetCompleted(*in.Completed)
+	}
+	if in.Tags != nil {
+		t.Tags = *in.Tags
+	}
+	if in.Priority != nil {
+		t.Priority = *in.Priority
+	}
+	if in.Recurring != nil {
+		t.Recurring = *in.Recurring
+	}
+	return nil
+}
+
+func (srv *Server) create(w http.ResponseWriter, r *http.Request) {
+	var in taskInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if in.Text == nil {
+		writeError(w, http.StatusBadRequest, errors.New("text is required"))
+		return
+	}
+	task := Task{UUID: newUUID()}
+	if err := in.apply(&task); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err := Modify(srv.Store, "add", func(tasks []Task) ([]Task, error) {
+		task.ID = NextID(tasks)
+		return append(tasks, task), nil
+	})
+	if err == nil {
+		task, err = srv.saved(task.UUID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", task.ID))
+	writeJSON(w, r, http.StatusCreated, task)
+}
+
+func (srv *Server) update(w http.ResponseWriter, r *http.Request, ref string) {
+	var in taskInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	srv.change(w, r, ref, "edit", func(t *Task) error {
+		if err := in.apply(t); err != nil {
+			return badRequest{err}
+		}
+		return nil
+	})
+}
+
+// change applies fn to the task ref points at, checking If-Match inside
+// the locked cycle so a concurrent change can't slip in between. The task
+// sent back is the one saved, with its modification times, so its ETag is
+// good for the next If-Match.
+func (srv *Server) change(w http.ResponseWriter, r *http.Request, ref, op string, fn func(t *Task) error) {
+	var task Task
+	err := Modify(srv.Store, op, func(tasks []Task) ([]Task, error) {
+		i, err := ResolveTask(tasks, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !matchesETag(r.Header.Get("If-Match"), tasks[i]) {
+			return nil, errStale
+		}
+		if err := fn(&tasks[i]); err != nil {
+			return nil, err
+		}
+		task = tasks[i]
+		return tasks, nil
+	})
+	if err == nil {
+		task, err = srv.saved(task.UUID)
+	}
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, task)
+}
+
+// saved reads a task back from the store after a change
+func (srv *Server) saved(uuid string) (Task, error) {
+	tasks, err := srv.Store.Load()
+	if err != nil {
+		return Task{}, err
+	}
+	for _, t := range tasks {
+		if t.UUID == uuid {
+			return t, nil
+		}
+	}
+	return Task{}, ErrTaskNotFound
+}
+
+func (srv *Server) delete(w http.ResponseWriter, r *http.Request, ref string) {
+	err := Modify(srv.Store, "delete", func(tasks []Task) ([]Task, error) {
+		i, err := ResolveTask(tasks, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !matchesETag(r.Header.Get("If-Match"), tasks[i]) {
+			return nil, errStale
+		}
+		return append(tasks[:i], tasks[i+1:]...), nil
+	})
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ETag returns a strong entity tag for v's JSON encoding
+func ETag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// matchesETag checks an If-Match header against a task. An empty header
+// (no precondition) always matches.
+func matchesETag(header string, t Task) bool {
+	if header == "" || header == "*" {
+		return true
+	}
+	tag := ETag(t)
+	for _, h := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(h), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+type badRequest struct{ error }
+
+func statusFor(err error) int {
+	var bad badRequest
+	switch {
+	case errors.Is(err, ErrTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errStale):
+		return http.StatusPreconditionFailed
+	case errors.As(err, &bad):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	tag := ETag(v)
+	w.Header().Set("ETag", tag)
+	if r.Method == http.MethodGet && r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,144 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestServerPatchIfMatch walks a client through fetching, changing and
+// deleting a task with ETags, in order: each step may send back an ETag
+// an earlier one saved.
+func TestServerPatchIfMatch(t *testing.T) {
+	srv := NewServer(NewMemoryStore())
+	steps := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		ifMatch     string // name of a saved ETag to send, if any
+		ifNoneMatch string
+		want        int
+		save        string // name to save the response ETag under
+		wantText    string
+	}{
+		{name: "create", method: "POST", path: "/tasks", body: `{"text":"Buy milk","tags":["shop"]}`, want: 201, save: "created", wantText: "Buy milk"},
+		{name: "get matches create", method: "GET", path: "/tasks/1", want: 200, save: "created"},
+		{name: "patch", method: "PATCH", path: "/tasks/1", body: `{"text":"Buy oat milk"}`, ifMatch: "created", want: 200, save: "patched", wantText: "Buy oat milk"},
+		{name: "get matches patch", method: "GET", path: "/tasks/1", want: 200, save: "patched"},
+		{name: "unchanged since patch", method: "GET", path: "/tasks/1", ifNoneMatch: "patched", want: 304},
+		{name: "patch again with the patch ETag", method: "PATCH", path: "/tasks/1", body: `{"priority":"high"}`, ifMatch: "patched", want: 200, save: "again"},
+		{name: "stale ETag", method: "PATCH", path: "/tasks/1", body: `{"text":"Buy soy milk"}`, ifMatch: "patched", want: 412},
+		{name: "stale patch changed nothing", method: "GET", path: "/tasks/1", want: 200, save: "again", wantText: "Buy oat milk"},
+		{name: "complete", method: "POST", path: "/tasks/1/complete", ifMatch: "again", want: 200, save: "done"},
+		{name: "bad due date", method: "PATCH", path: "/tasks/1", body: `{"due":"whenever"}`, ifMatch: "done", want: 400},
+		{name: "unknown task", method: "PATCH", path: "/tasks/9", body: `{"text":"x"}`, want: 404},
+		{name: "delete with stale ETag", method: "DELETE", path: "/tasks/1", ifMatch: "again", want: 412},
+		{name: "delete", method: "DELETE", path: "/tasks/1", ifMatch: "done", want: 204},
+		{name: "gone", method: "GET", path: "/tasks/1", want: 404},
+	}
+
+	etags := map[string]string{}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if step.ifMatch != "" {
+			req.Header.Set("If-Match", etags[step.ifMatch])
+		}
+		if step.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", etags[step.ifNoneMatch])
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if rec.Code != step.want {
+			t.Fatalf("%s: %s %s = %d, want %d: %s", step.name, step.method, step.path, rec.Code, step.want, rec.Body)
+		}
+		if step.save != "" {
+			tag := rec.Header().Get("ETag")
+			if saved, ok := etags[step.save]; ok && saved != tag {
+				t.Errorf("%s: ETag %s, want %s", step.name, tag, saved)
+			}
+			etags[step.save] = tag
+		}
+		if step.wantText != "" {
+			var task Task
+			if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if task.Text != step.wantText {
+				t.Errorf("%s: text %q, want %q", step.name, task.Text, step.wantText)
+			}
+			if tag := rec.Header().Get("ETag"); tag != ETag(task) {
+				t.Errorf("%s: ETag %s is not the body's %s", step.name, tag, ETag(task))
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("tasks left over: %s", body)
+	}
+}
+
+func TestServerRoutesAndFilters(t *testing.T) {
+	s := NewMemoryStore()
+	for _, text := range []string{"Buy milk", "Call mom"} {
+		if err := AddTaskWithDueDate(s, text, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MarkTaskDone(s, "1"); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(s)
+
+	tests := []struct {
+		method, path string
+		want         int
+		wantTexts    []string // for a 200 from /tasks
+		wantAllow    string
+	}{
+		{method: "GET", path: "/tasks", want: 200, wantTexts: []string{"Buy milk", "Call mom"}},
+		{method: "GET", path: "/tasks?done", want: 200, wantTexts: []string{"Buy milk"}},
+		{method: "GET", path: "/tasks?done=true", want: 200, wantTexts: []string{"Buy milk"}},
+		{method: "GET", path: "/tasks?done=false", want: 200, wantTexts: []string{"Buy milk", "Call mom"}},
+		{method: "GET", path: "/tasks?pending=1", want: 200, wantTexts: []string{"Call mom"}},
+		{method: "GET", path: "/tasks?done=maybe", want: 400},
+		{method: "PUT", path: "/tasks/2", want: 405, wantAllow: "GET, PATCH, DELETE"},
+		{method: "GET", path: "/tasks/2/complete", want: 405, wantAllow: "POST"},
+		{method: "POST", path: "/tasks/2/foo", want: 404},
+		{method: "GET", path: "/tasks/2/foo", want: 404},
+		{method: "GET", path: "/notes", want: 404},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"text":"Replaced"}`)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.wantAllow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.path, allow, tt.wantAllow)
+		}
+		if tt.wantTexts == nil {
+			continue
+		}
+		var tasks []Task
+		if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+		var texts []string
+		for _, task := range tasks {
+			texts = append(texts, task.Text)
+		}
+		if strings.Join(texts, ", ") != strings.Join(tt.wantTexts, ", ") {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.path, texts, tt.wantTexts)
+		}
+	}
+	if got := taskTexts(t, s); got[1] != "Call mom" {
+		t.Errorf("a refused request changed task 2 to %q", got[1])
+	}
+}
@@ -0,0 +1,493 @@
+package todo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id        INTEGER PRIMARY KEY,
+	text      TEXT    NOT NULL,
+	completed INTEGER NOT NULL DEFAULT 0,
+	due_date  TEXT    NOT NULL DEFAULT '',
+	priority  TEXT    NOT NULL DEFAULT '',
+	position  INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date);
+
+CREATE TABLE IF NOT EXISTS tags (
+	task_id  INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+	tag      TEXT    NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (task_id, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_tags_tag ON tags(tag COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS recurrence (
+	task_id INTEGER PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+	rule    TEXT    NOT NULL
+);
+`
+
+// sqliteMigrations upgrade the database from user_version i to i+1
+var sqliteMigrations = []func(tx *sql.Tx) error{
+	// 1: keep attributes Task has no column for
+	execSQL(`ALTER TABLE tasks ADD COLUMN extra TEXT NOT NULL DEFAULT ''`),
+	// 2: stable UUIDs alongside the display IDs
+	func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`ALTER TABLE tasks ADD COLUMN uuid TEXT NOT NULL DEFAULT ''`); err != nil {
+			return err
+		}
+		rows, err := tx.Query(`SELECT id FROM tasks`)
+		if err != nil {
+			return err
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		for _, id := range ids {
+			if _, err := tx.Exec(`UPDATE tasks SET uuid = ? WHERE id = ?`, newUUID(), id); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 3: when a task was completed
+	execSQL(`ALTER TABLE tasks ADD COLUMN completed_at TEXT NOT NULL DEFAULT ''`),
+	// 4: when each field last changed, as JSON
+	execSQL(`ALTER TABLE tasks ADD COLUMN modified TEXT NOT NULL DEFAULT ''`),
+	// 5: due times and durations
+	execSQL(`ALTER TABLE tasks ADD COLUMN due_time TEXT NOT NULL DEFAULT '';
+		ALTER TABLE tasks ADD COLUMN duration TEXT NOT NULL DEFAULT ''`),
+	// 6: subtasks
+	execSQL(`ALTER TABLE tasks ADD COLUMN parent TEXT NOT NULL DEFAULT ''`),
+	// 7: notes, as JSON
+	execSQL(`ALTER TABLE tasks ADD COLUMN notes TEXT NOT NULL DEFAULT ''`),
+}
+
+// SQLiteStore keeps tasks in an embedded SQLite database. Tags and
+// recurrence rules live in their own tables, so single-task changes
+// don't rewrite the whole list.
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// NewSQLiteStore opens (and if needed creates) the database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+// Close releases the database handle
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Lock takes the advisory lock shared with other processes using the
+// same database
+func (s *SQLiteStore) Lock() (func() error, error) {
+	return lockFile(s.path + ".lock")
+}
+
+// Load returns every task in insertion order
+func (s *SQLiteStore) Load() ([]Task, error) {
+	return s.query("", nil)
+}
+
+// Save stores the task list in one transaction. Only rows that changed
+// are written: a toggle updates one row, a delete removes one.
+func (s *SQLiteStore) Save(tasks []Task) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, err := queryTasks(tx, "", nil)
+	if err != nil {
+		return err
+	}
+	old := make(map[int]Task, len(stored))
+	for _, t := range stored {
+		old[t.ID] = t
+	}
+	positions, err := storedPositions(tx)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[int]bool, len(tasks))
+	prev := -1
+	for _, t := range tasks {
+		if keep[t.ID] {
+			return fmt.Errorf("duplicate task ID %d", t.ID)
+		}
+		keep[t.ID] = true
+
+		// Keep a row's position while the order allows it, so removing or
+		// appending a task leaves the other rows alone
+		pos := prev + 1
+		if p, ok := positions[t.ID]; ok && p > prev {
+			pos = p
+		}
+		prev = pos
+
+		prior, ok := old[t.ID]
+		switch {
+		case !ok:
+			err = insertTask(tx, t, pos)
+		case !sameRow(prior, t):
+			err = updateTask(tx, t, pos)
+		case positions[t.ID] != pos:
+			_, err = tx.Exec(`UPDATE tasks SET position = ? WHERE id = ?`, pos, t.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for id := range old {
+		if !keep[id] {
+			if _, err := tx.Exec(`DELETE FROM tasks WHERE id = ?`, id); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func storedPositions(tx *sql.Tx) (map[int]int, error) {
+	rows, err := tx.Query(`SELECT id, position FROM tasks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	positions := map[int]int{}
+	for rows.Next() {
+		var id, pos int
+		if err := rows.Scan(&id, &pos); err != nil {
+			return nil, err
+		}
+		positions[id] = pos
+	}
+	return positions, rows.Err()
+}
+
+// sameRow reports whether storing b over a would change nothing
+func sameRow(a, b Task) bool {
+	if a.UUID != b.UUID || !sameFields(a, b) {
+		return false
+	}
+	am, _ := encodeModified(a.Modified)
+	bm, _ := encodeModified(b.Modified)
+	return am == bm
+}
+
+// Get looks up a single task by ID
+func (s *SQLiteStore) Get(id int) (Task, error) {
+	tasks, err := s.query("WHERE t.id = ?", []any{id})
+	if err != nil {
+		return Task{}, err
+	}
+	if len(tasks) == 0 {
+		return Task{}, ErrTaskNotFound
+	}
+	return tasks[0], nil
+}
+
+// Update rewrites a single task row along with its tags and recurrence
+func (s *SQLiteStore) Update(task Task) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var pos int
+	if err := tx.QueryRow(`SELECT position FROM tasks WHERE id = ?`, task.ID).Scan(&pos); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	if err := updateTask(tx, task, pos); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Query runs the list filters as SQL instead of filtering in memory
+func (s *SQLiteStore) Query(opts ListFilterOptions) ([]Task, error) {
+	var where []string
+	var args []any
+	today := time.Now().Format("2006-01-02")
+
+	if opts.ShowDone {
+		where = append(where, "t.completed = 1")
+	}
+	if opts.ShowPending {
+		where = append(where, "t.completed = 0")
+	}
+	if opts.TodayOnly {
+		where = append(where, "t.due_date = ?")
+		args = append(args, today)
+	}
+	if opts.OverdueOnly {
+		where = append(where, "t.due_date != '' AND t.due_date <= ?")
+		args = append(args, today)
+	}
+	if opts.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM tags g WHERE g.task_id = t.id AND g.tag = ? COLLATE NOCASE)")
+		args = append(args, opts.Tag)
+	}
+	if opts.Priority != "" {
+		where = append(where, "lower(t.priority) = ?")
+		args = append(args, opts.Priority)
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+	return s.query(clause, args)
+}
+
+func (s *SQLiteStore) query(where string, args []any) ([]Task, error) {
+	return queryTasks(s.db, where, args)
+}
+
+// sqlQuerier is a *sql.DB or a *sql.Tx
+type sqlQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func queryTasks(db sqlQuerier, where string, args []any) ([]Task, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.uuid, t.text, t.completed, t.completed_at, t.due_date, t.due_time, t.duration, t.priority, t.parent, t.notes, t.extra, t.modified, COALESCE(r.rule, '')
+		FROM tasks t LEFT JOIN recurrence r ON r.task_id = t.id
+		`+where+`
+		ORDER BY t.position, t.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	index := map[int]int{}
+	for rows.Next() {
+		var t Task
+		var notes, extra, modified string
+		if err := rows.Scan(&t.ID, &t.UUID, &t.Text, &t.Completed, &t.CompletedAt, &t.DueDate, &t.DueTime, &t.Duration, &t.Priority, &t.Parent, &notes, &extra, &modified, &t.Recurring); err != nil {
+			return nil, err
+		}
+		if notes != "" {
+			if err := json.Unmarshal([]byte(notes), &t.Notes); err != nil {
+				return nil, err
+			}
+		}
+		if extra != "" {
+			if err := json.Unmarshal([]byte(extra), &t.Extra); err != nil {
+				return nil, err
+			}
+		}
+		if modified != "" {
+			if err := json.Unmarshal([]byte(modified), &t.Modified); err != nil {
+				return nil, err
+			}
+		}
+		index[t.ID] = len(tasks)
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return tasks, nil
+	}
+
+	tagRows, err := db.Query(`
+		SELECT g.task_id, g.tag
+		FROM tags g JOIN tasks t ON t.id = g.task_id
+		`+where+`
+		ORDER BY g.task_id, g.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var id int
+		var tag string
+		if err := tagRows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			tasks[i].Tags = append(tasks[i].Tags, tag)
+		}
+	}
+	return tasks, tagRows.Err()
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database is schema version %d, this build only understands up to %d", version, len(sqliteMigrations))
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := sqliteMigrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrating database to version %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execSQL(stmt string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmt)
+		return err
+	}
+}
+
+func encodeExtra(extra map[string]json.RawMessage) (string, error) {
+	if len(extra) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(extra)
+	return string(data), err
+}
+
+func encodeModified(modified map[string]time.Time) (string, error) {
+	if len(modified) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(modified)
+	return string(data), err
+}
+
+func encodeNotes(notes []Note) (string, error) {
+	if len(notes) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(notes)
+	return string(data), err
+}
+
+func insertTask(tx *sql.Tx, t Task, pos int) error {
+	extra, modified, notes, err := encodeColumns(t)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO tasks (id, uuid, text, completed, completed_at, due_date, due_time, duration, priority, parent, notes, position, extra, modified) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.UUID, t.Text, t.Completed, t.CompletedAt, t.DueDate, t.DueTime, t.Duration, t.Priority, t.Parent, notes, pos, extra, modified); err != nil {
+		return err
+	}
+	return writeTaskExtras(tx, t)
+}
+
+func updateTask(tx *sql.Tx, t Task, pos int) error {
+	extra, modified, notes, err := encodeColumns(t)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE tasks SET uuid = ?, text = ?, completed = ?, completed_at = ?, due_date = ?, due_time = ?, duration = ?, priority = ?, parent = ?, notes = ?, position = ?, extra = ?, modified = ? WHERE id = ?`,
+		t.UUID, t.Text, t.Completed, t.CompletedAt, t.DueDate, t.DueTime, t.Duration, t.Priority, t.Parent, notes, pos, extra, modified, t.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE task_id = ?`, t.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recurrence WHERE task_id = ?`, t.ID); err != nil {
+		return err
+	}
+	return writeTaskExtras(tx, t)
+}
+
+// encodeColumns is the JSON held in the extra, modified and notes columns
+func encodeColumns(t Task) (extra, modified, notes string, err error) {
+	if extra, err = encodeExtra(t.Extra); err != nil {
+		return
+	}
+	if modified, err = encodeModified(t.Modified); err != nil {
+		return
+	}
+	notes, err = encodeNotes(t.Notes)
+	return
+}
+
+func writeTaskExtras(tx *sql.Tx, t Task) error {
+	for i, tag := range t.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (task_id, tag, position) VALUES (?, ?, ?)`, t.ID, tag, i); err != nil {
+			return err
+		}
+	}
+	if t.Recurring != "" {
+		if _, err := tx.Exec(`INSERT INTO recurrence (task_id, rule) VALUES (?, ?)`, t.ID, t.Recurring); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateTasks copies every task from one store into another, replacing
+// whatever the destination held. Old files can hold the same ID twice;
+// the later tasks get new IDs, as the destination may key on them.
+// Returns the number of tasks copied.
+func MigrateTasks(from, to Store) (int, error) {
+	tasks, err := from.Load()
+	if err != nil {
+		return 0, err
+	}
+	if len(tasks) == 0 {
+		return 0, errors.New("no tasks to migrate")
+	}
+	seen := map[int]bool{}
+	for i := range tasks {
+		if tasks[i].ID <= 0 || seen[tasks[i].ID] {
+			tasks[i].ID = NextID(tasks)
+		}
+		seen[tasks[i].ID] = true
+	}
+	if err := to.Save(tasks); err != nil {
+		return 0, err
+	}
+	return len(tasks), nil
+}
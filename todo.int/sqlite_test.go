@@ -0,0 +1,165 @@
+package todo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// countWrites makes the database count the rows written to tasks, and
+// returns a func that reads and resets the count
+func countWrites(t *testing.T, s *SQLiteStore) func() int {
+	t.Helper()
+	_, err := s.db.Exec(`
+		CREATE TABLE writes (n INTEGER);
+		CREATE TRIGGER count_insert AFTER INSERT ON tasks BEGIN INSERT INTO writes VALUES (1); END;
+		CREATE TRIGGER count_update AFTER UPDATE ON tasks BEGIN INSERT INTO writes VALUES (1); END;
+		CREATE TRIGGER count_delete AFTER DELETE ON tasks BEGIN INSERT INTO writes VALUES (1); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() int {
+		var n int
+		if err := s.db.QueryRow(`SELECT count(*) FROM writes`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.db.Exec(`DELETE FROM writes`); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+}
+
+func TestSQLiteSaveWritesOnlyChangedRows(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 1; i <= 5; i++ {
+		if err := AddTaskWithDueDate(s, fmt.Sprint("task ", i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writes := countWrites(t, s)
+
+	tests := []struct {
+		name string
+		do   func() error
+		want int
+	}{
+		{"done", func() error { return MarkTaskDone(s, "3") }, 1},
+		{"tags", func() error { return SetTags(s, 2, []string{"home", "errand"}) }, 1},
+		{"due", func() error { return SetDueDate(s, "4", "2026-10-20") }, 1},
+		{"edit", func() error { return EditTaskText(s, "5", "task five") }, 1},
+		{"toggle", func() error { return ToggleTaskDone(s, 5) }, 1},
+		{"delete from the middle", func() error { return DeleteTask(s, "2") }, 1},
+		{"add", func() error { return AddTaskWithDueDate(s, "task 6", "") }, 1},
+		{"no change", func() error {
+			return Modify(s, "noop", func(tasks []Task) ([]Task, error) { return tasks, nil })
+		}, 0},
+	}
+	for _, tt := range tests {
+		if err := tt.do(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := writes(); got != tt.want {
+			t.Errorf("%s wrote %d rows, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, task := range tasks {
+		texts = append(texts, task.Text)
+	}
+	if want := []string{"task 1", "task 3", "task 4", "task five", "task 6"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("tasks = %q, want %q", texts, want)
+	}
+	if !tasks[1].Completed || tasks[2].DueDate != "2026-10-20" {
+		t.Errorf("changes lost: %+v", tasks)
+	}
+}
+
+func TestSQLiteSaveKeepsOrder(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tasks := []Task{{ID: 1, Text: "a"}, {ID: 2, Text: "b"}, {ID: 3, Text: "c"}}
+	if err := s.Save(tasks); err != nil {
+		t.Fatal(err)
+	}
+	// Move the last task to the front and put a new one in the middle
+	reordered := []Task{{ID: 3, Text: "c"}, {ID: 1, Text: "a"}, {ID: 4, Text: "d"}, {ID: 2, Text: "b"}}
+	if err := s.Save(reordered); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, task := range got {
+		ids = append(ids, task.ID)
+	}
+	if len(ids) != 4 || ids[0] != 3 || ids[1] != 1 || ids[2] != 4 || ids[3] != 2 {
+		t.Errorf("order = %v, want [3 1 4 2]", ids)
+	}
+	if err := s.Save([]Task{{ID: 1, Text: "a"}, {ID: 1, Text: "again"}}); err == nil {
+		t.Error("saved two tasks with ID 1")
+	}
+}
+
+func TestMigrateTasksToSQLite(t *testing.T) {
+	dir := t.TempDir()
+	// A legacy bare-array file, written before IDs were collision-free
+	legacy := `[
+		{"id": 1, "text": "Buy milk", "completed": false, "tags": ["shop"]},
+		{"id": 2, "text": "Call mom", "completed": true},
+		{"id": 2, "text": "Pay rent", "completed": false, "recurring": "monthly"},
+		{"id": 0, "text": "No ID", "completed": false}
+	]`
+	jsonPath := filepath.Join(dir, "tasks.json")
+	if err := os.WriteFile(jsonPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewSQLiteStore(filepath.Join(dir, "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	n, err := MigrateTasks(NewJSONStore(jsonPath), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("migrated %d tasks, want 4", n)
+	}
+	tasks, err := db.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id   int
+		text string
+	}{{1, "Buy milk"}, {2, "Call mom"}, {3, "Pay rent"}, {4, "No ID"}}
+	if len(tasks) != len(want) {
+		t.Fatalf("loaded %d tasks, want %d", len(tasks), len(want))
+	}
+	for i, w := range want {
+		if tasks[i].ID != w.id || tasks[i].Text != w.text || tasks[i].UUID == "" {
+			t.Errorf("task %d = %d %q (uuid %q), want %d %q", i, tasks[i].ID, tasks[i].Text, tasks[i].UUID, w.id, w.text)
+		}
+	}
+	if len(tasks[0].Tags) != 1 || tasks[2].Recurring != "monthly" || !tasks[1].Completed {
+		t.Errorf("fields lost: %+v", tasks)
+	}
+}
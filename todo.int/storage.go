@@ -2,32 +2,239 @@
 package todo
 
 import (
-	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
 )
 
-const filename = "tasks.json"
+// ErrTaskNotFound is returned when no task matches the given ID.
+var ErrTaskNotFound = errors.New("task not found")
 
-// LoadTasks reads tasks from a file
-func LoadTasks() ([]Task, error) {
-	var tasks []Task
-	file, err := os.ReadFile(filename)
+// Store is a persistence backend for the task list.
+type Store interface {
+	// Load returns every task in the store.
+	Load() ([]Task, error)
+	// Save replaces the stored task list.
+	Save(tasks []Task) error
+	// Get returns the task with the given ID.
+	Get(id int) (Task, error)
+	// Update replaces the stored task that has the same ID as task.
+	Update(task Task) error
+}
+
+// Locker is implemented by stores that can hold an exclusive lock across a
+// whole load-modify-save cycle, shared with other processes.
+type Locker interface {
+	Lock() (unlock func() error, err error)
+}
+
+// Modify runs one load-modify-save cycle. When the store is a Locker the
+// lock is held for the whole cycle, so concurrent writers (the TUI and a
+// shell script, say) can't overwrite each other's changes. op names the
+// operation ("add", "done", ...) for stores that record changes.
+func Modify(s Store, op string, fn func(tasks []Task) ([]Task, error)) error {
+	return WithLock(s, func() error {
+		return modify(s, op, fn)
+	})
+}
+
+// WithLock runs fn while holding the store's lock, if it has one
+func WithLock(s Store, fn func() error) (err error) {
+	if l, ok := s.(Locker); ok {
+		unlock, err := l.Lock()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if uerr := unlock(); err == nil {
+				err = uerr
+			}
+		}()
+	}
+	return fn()
+}
+
+func modify(s Store, op string, fn func(tasks []Task) ([]Task, error)) error {
+	tasks, err := s.Load()
+	if err != nil {
+		return err
+	}
+	assignUUIDs(tasks)
+	before := cloneTasks(tasks)
+
+	tasks, err = fn(tasks)
+	if err != nil {
+		return err
+	}
+	assignUUIDs(tasks)
+	now := time.Now()
+	// A sync carries over the modification times of the replica it merged
+	if op != "sync" {
+		stampModified(before, tasks, now)
+	}
+	if err := s.Save(tasks); err != nil {
+		return err
+	}
+
+	if r, ok := s.(Recorder); ok {
+		change := Change{Op: op, Time: now, Before: before, After: cloneTasks(tasks)}
+		if err := r.Record(change); err != nil {
+			return recordError{err}
+		}
+	}
+	return nil
+}
+
+// recordError is modify's error when the tasks were saved but a recorder
+// failed afterwards
+type recordError struct{ err error }
+
+func (e recordError) Error() string {
+	return "saved, but recording the change failed: " + e.err.Error()
+}
+func (e recordError) Unwrap() error { return e.err }
+
+func assignUUIDs(tasks []Task) {
+	for i := range tasks {
+		if tasks[i].UUID == "" {
+			tasks[i].UUID = newUUID()
+		}
+	}
+}
+
+// JSONStore keeps tasks in a JSON file
+type JSONStore struct {
+	Path string
+	// Encryption, when set, keeps the file encrypted at rest
+	Encryption *Encryption
+
+	locked atomic.Bool // this store holds the file's lock
+}
+
+// NewJSONStore returns a store backed by the JSON file at path
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{Path: path}
+}
+
+// Load reads tasks from the file
+func (s *JSONStore) Load() ([]Task, error) {
+	file, err := readSealed(s.Path, s.Encryption)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return []Task{}, nil
 		}
 		return nil, err
 	}
-	err = json.Unmarshal(file, &tasks)
+	tasks, upgraded, err := decodeTaskFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if upgraded && !s.locked.Load() {
+		// Persist the migration straight away so generated values (like
+		// UUIDs) stay the same between runs. Under the lock, the save
+		// that ends the cycle does it.
+		return s.upgrade()
+	}
+	return tasks, nil
+}
+
+// upgrade rewrites an old task file in the current schema, under the lock.
+// The file is read again, as another process may have upgraded it first.
+func (s *JSONStore) upgrade() (tasks []Task, err error) {
+	err = WithLock(s, func() error {
+		file, err := readSealed(s.Path, s.Encryption)
+		if err != nil {
+			return err
+		}
+		var upgraded bool
+		if tasks, upgraded, err = decodeTaskFile(file); err != nil || !upgraded {
+			return err
+		}
+		return s.Save(tasks)
+	})
 	return tasks, err
 }
 
-// SaveTasks writes tasks to a file
-func SaveTasks(tasks []Task) error {
-	data, err := json.MarshalIndent(tasks, "", "  ")
+// Save writes tasks to the file. It writes a temp file next to it and
+// renames it into place, so a crash mid-write never leaves a truncated file.
+func (s *JSONStore) Save(tasks []Task) error {
+	data, err := encodeTaskFile(tasks)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filename, data, 0644)
-}
\ No newline at end of file
+	return writeSealed(s.Path, data, s.Encryption)
+}
+
+// Lock takes the advisory lock guarding the file
+func (s *JSONStore) Lock() (func() error, error) {
+	unlock, err := lockFile(s.Path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	s.locked.Store(true)
+	return func() error {
+		s.locked.Store(false)
+		return unlock()
+	}, nil
+}
+
+// Get looks up a single task by ID
+func (s *JSONStore) Get(id int) (Task, error) {
+	tasks, err := s.Load()
+	if err != nil {
+		return Task{}, err
+	}
+	return findByID(tasks, id)
+}
+
+// Update rewrites a single task in the file
+func (s *JSONStore) Update(task Task) error {
+	return Modify(s, "update", func(tasks []Task) ([]Task, error) {
+		return tasks, replaceByID(tasks, task)
+	})
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func findByID(tasks []Task, id int) (Task, error) {
+	for _, t := range tasks {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return Task{}, ErrTaskNotFound
+}
+
+func replaceByID(tasks []Task, task Task) error {
+	for i := range tasks {
+		if tasks[i].ID == task.ID {
+			tasks[i] = task
+			return nil
+		}
+	}
+	return ErrTaskNotFound
+}
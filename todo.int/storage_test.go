@@ -0,0 +1,227 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// legacyFile is a task file from before schema versions and UUIDs
+const legacyFile = `[{"id": 1, "text": "Buy milk", "completed": false}]`
+
+func TestJSONStoreUpgradesUnderTheLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.WriteFile(path, []byte(legacyFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewJSONStore(path)
+
+	// Another process holds the lock: the upgrade waits for it
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := make(chan []Task)
+	go func() {
+		tasks, err := s.Load()
+		if err != nil {
+			t.Error(err)
+		}
+		loaded <- tasks
+	}()
+	select {
+	case <-loaded:
+		t.Fatal("Load upgraded the file while another process held the lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if data, _ := os.ReadFile(path); string(data) != legacyFile {
+		t.Fatalf("file written while locked:\n%s", data)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	tasks := <-loaded
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"version": 2`) || !strings.Contains(string(data), tasks[0].UUID) {
+		t.Errorf("file not upgraded with the UUID Load returned:\n%s", data)
+	}
+	again, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0].UUID != tasks[0].UUID {
+		t.Errorf("UUID changed between loads: %s, %s", tasks[0].UUID, again[0].UUID)
+	}
+}
+
+func TestJSONStoreUpgradeInsideModify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.WriteFile(path, []byte(legacyFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewJSONStore(path)
+
+	// Loading under the store's own lock mustn't wait for it; the save
+	// that ends the cycle writes the upgraded file
+	done := make(chan error)
+	go func() { done <- MarkTaskDone(s, "1") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Modify deadlocked upgrading the file")
+	}
+	tasks, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tasks[0].Completed || tasks[0].UUID == "" {
+		t.Errorf("task = %+v, want done with a UUID", tasks[0])
+	}
+}
+
+// storeBackends opens an empty store of each kind for the contract tests
+var storeBackends = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store { return NewMemoryStore() },
+	"json": func(t *testing.T) Store {
+		return NewJSONStore(filepath.Join(t.TempDir(), "tasks.json"))
+	},
+	"sqlite": func(t *testing.T) Store {
+		s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	},
+}
+
+// sameTask compares every field a store has to keep
+func sameTask(a, b Task) bool {
+	if a.ID != b.ID || a.UUID != b.UUID || a.CompletedAt != b.CompletedAt ||
+		!sameFields(a, b) || len(a.Modified) != len(b.Modified) {
+		return false
+	}
+	for field, at := range a.Modified {
+		if !at.Equal(b.Modified[field]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStoreContract(t *testing.T) {
+	modified := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	tasks := []Task{
+		{
+			ID: 1, UUID: "aaaa0000-0000-4000-8000-000000000001", Text: "Write report",
+			DueDate: "2026-10-20", DueTime: "09:30", Duration: "1h30m",
+			Tags: []string{"work", "@office"}, Priority: "high", Recurring: "weekly",
+			Notes:    []Note{{At: "2026-10-01T09:30:00Z", Text: "outline done"}},
+			Modified: map[string]time.Time{"text": modified, "due": modified},
+			Extra:    map[string]json.RawMessage{"estimate": json.RawMessage(`"3h"`)},
+		},
+		{
+			ID: 2, UUID: "aaaa0000-0000-4000-8000-000000000002", Text: "Proofread",
+			Completed: true, CompletedAt: "2026-10-02T17:00:00Z",
+			Parent: "aaaa0000-0000-4000-8000-000000000001",
+		},
+		{ID: 5, UUID: "aaaa0000-0000-4000-8000-000000000005", Text: "Send it"},
+	}
+
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			if got, err := s.Load(); err != nil || len(got) != 0 {
+				t.Fatalf("empty store Load = %v, %v", got, err)
+			}
+			if err := s.Save(tasks); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tasks) {
+				t.Fatalf("loaded %d tasks, want %d", len(got), len(tasks))
+			}
+			for i := range tasks {
+				if !sameTask(got[i], tasks[i]) {
+					t.Errorf("task %d came back as\n  %+v\nwant\n  %+v", i, got[i], tasks[i])
+				}
+			}
+
+			// Changing what Load returned doesn't reach the store
+			got[0].Tags[0] = "changed"
+			if task, err := s.Get(1); err != nil || task.Tags[0] != "work" {
+				t.Errorf("Get(1) = %+v, %v; want it unchanged", task, err)
+			}
+			if _, err := s.Get(3); !errors.Is(err, ErrTaskNotFound) {
+				t.Errorf("Get(3) = %v, want ErrTaskNotFound", err)
+			}
+
+			edited := tasks[2]
+			edited.Text = "Send it today"
+			if err := s.Update(edited); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Update(Task{ID: 9, Text: "nope"}); !errors.Is(err, ErrTaskNotFound) {
+				t.Errorf("Update of a missing task = %v, want ErrTaskNotFound", err)
+			}
+			if got := taskTexts(t, s); !reflect.DeepEqual(got, []string{"Write report", "Proofread", "Send it today"}) {
+				t.Errorf("after Update: %q", got)
+			}
+
+			if err := s.Save(tasks[1:2]); err != nil {
+				t.Fatal(err)
+			}
+			if got := taskTexts(t, s); !reflect.DeepEqual(got, []string{"Proofread"}) {
+				t.Errorf("after saving fewer tasks: %q", got)
+			}
+		})
+	}
+}
+
+func TestStoreConcurrentModify(t *testing.T) {
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := AddTaskWithDueDate(s, fmt.Sprint("task ", i), ""); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			tasks, err := s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := map[int]bool{}
+			for _, task := range tasks {
+				ids[task.ID] = true
+			}
+			if len(tasks) != 10 || len(ids) != 10 {
+				t.Errorf("%d tasks with %d distinct IDs after 10 concurrent adds, want 10", len(tasks), len(ids))
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// syncField is one part of a task that is merged on its own, with its own
+// modification time in Task.Modified
+type syncField struct {
+	name string
+	get  func(t Task) any
+	set  func(dst *Task, src Task)
+}
+
+var syncFields = []syncField{
+	{"text", func(t Task) any { return t.Text }, func(d *Task, s Task) { d.Text = s.Text }},
+	{"completed", func(t Task) any { return [2]any{t.Completed, t.CompletedAt} }, func(d *Task, s Task) {
+		d.Completed, d.CompletedAt = s.Completed, s.CompletedAt
+	}},
+	{"due_date", func(t Task) any { return t.DueLabel() }, func(d *Task, s Task) {
+		d.DueDate, d.DueTime, d.Duration = s.DueDate, s.DueTime, s.Duration
+	}},
+	{"tags", func(t Task) any {
+		if len(t.Tags) == 0 {
+			return []string(nil)
+		}
+		return t.Tags
+	}, func(d *Task, s Task) { d.Tags = append([]string(nil), s.Tags...) }},
+	{"priority", func(t Task) any { return t.Priority }, func(d *Task, s Task) { d.Priority = s.Priority }},
+	{"recurring", func(t Task) any { return t.Recurring }, func(d *Task, s Task) { d.Recurring = s.Recurring }},
+	{"parent", func(t Task) any { return t.Parent }, func(d *Task, s Task) { d.Parent = s.Parent }},
+	{"notes", func(t Task) any {
+		if len(t.Notes) == 0 {
+			return []Note(nil)
+		}
+		return t.Notes
+	}, func(d *Task, s Task) { d.Notes = append([]Note(nil), s.Notes...) }},
+	{"extra", func(t Task) any {
+		if len(t.Extra) == 0 {
+			return map[string]string(nil)
+		}
+		// Compacted, so the same value indented differently compares equal
+		extra := make(map[string]string, len(t.Extra))
+		for k, v := range t.Extra {
+			var buf bytes.Buffer
+			if json.Compact(&buf, v) != nil {
+				buf.Reset()
+				buf.Write(v)
+			}
+			extra[k] = buf.String()
+		}
+		return extra
+	}, func(d *Task, s Task) { d.Extra = cloneTasks([]Task{s})[0].Extra }},
+}
+
+// stampModified records, in Task.Modified, when each field of each task
+// changed going from before to after
+func stampModified(before, after []Task, now time.Time) {
+	old := map[string]Task{}
+	for _, t := range before {
+		old[t.UUID] = t
+	}
+	for i := range after {
+		b, ok := old[after[i].UUID]
+		if !ok {
+			continue
+		}
+		for _, f := range syncFields {
+			if reflect.DeepEqual(f.get(b), f.get(after[i])) {
+				continue
+			}
+			if after[i].Modified == nil {
+				after[i].Modified = map[string]time.Time{}
+			}
+			after[i].Modified[f.name] = now
+		}
+	}
+}
+
+// Side picks one replica's version in a sync conflict
+type Side int
+
+const (
+	Local Side = iota
+	Remote
+)
+
+// Conflict is a task both replicas changed differently since they last
+// synced. Field is the field in question, or "deleted" when one side
+// deleted the task and the other edited it.
+type Conflict struct {
+	Task          Task
+	Field         string
+	Local, Remote string
+	// LocalTime and RemoteTime are when each side last changed the field,
+	// if known
+	LocalTime, RemoteTime time.Time
+}
+
+// Newer returns the side that changed the field most recently, preferring
+// Local when that isn't known
+func (c Conflict) Newer() Side {
+	if c.RemoteTime.After(c.LocalTime) {
+		return Remote
+	}
+	return Local
+}
+
+// SyncResult sums up what a sync did
+type SyncResult struct {
+	Pulled    int // tasks added, changed or deleted locally
+	Pushed    int // tasks added, changed or deleted in the replica
+	Conflicts int
+}
+
+// MergeTasks does a three-way merge of two replicas of a list against
+// base, their last common state. Fields changed on one side only are taken
+// from that side. Fields changed on both sides are true conflicts and are
+// settled by resolve. When there's no common state for a task (the first
+// sync, say) the per-field modification times decide, and resolve is only
+// asked when they don't.
+func MergeTasks(base, local, remote []Task, resolve func(Conflict) Side) []Task {
+	baseBy, remoteBy := indexByUUID(base), indexByUUID(remote)
+	localBy := indexByUUID(local)
+	merged := []Task{}
+
+	for _, l := range local {
+		b, inBase := baseBy[l.UUID]
+		r, inRemote := remoteBy[l.UUID]
+		switch {
+		case inRemote:
+			merged = append(merged, mergeTask(b, l, r, inBase, resolve))
+		case !inBase:
+			// Added locally
+			merged = append(merged, l)
+		case sameFields(b, l):
+			// Deleted remotely, untouched here
+		default:
+			c := Conflict{Task: l, Field: "deleted", Local: "edited", Remote: "deleted", LocalTime: lastModified(l)}
+			if resolve(c) == Local {
+				merged = append(merged, l)
+			}
+		}
+	}
+
+	for _, r := range remote {
+		if _, ok := localBy[r.UUID]; ok {
+			continue
+		}
+		b, inBase := baseBy[r.UUID]
+		switch {
+		case !inBase:
+			// Added remotely
+		case sameFields(b, r):
+			// Deleted locally, untouched there
+			continue
+		default:
+			c := Conflict{Task: r, Field: "deleted", Local: "deleted", Remote: "edited", RemoteTime: lastModified(r)}
+			if resolve(c) == Local {
+				continue
+			}
+		}
+		if _, err := findByID(merged, r.ID); err == nil {
+			r.ID = NextID(merged)
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func mergeTask(b, l, r Task, inBase bool, resolve func(Conflict) Side) Task {
+	out := l
+	out.Modified = copyModified(l.Modified)
+	for _, f := range syncFields {
+		lv, rv := f.get(l), f.get(r)
+		if reflect.DeepEqual(lv, rv) {
+			continue
+		}
+		var take Side
+		switch {
+		case inBase && reflect.DeepEqual(f.get(b), lv):
+			take = Remote
+		case inBase && reflect.DeepEqual(f.get(b), rv):
+			take = Local
+		default:
+			c := Conflict{
+				Task: l, Field: f.name,
+				Local: formatField(lv), Remote: formatField(rv),
+				LocalTime: l.Modified[f.name], RemoteTime: r.Modified[f.name],
+			}
+			if !inBase && !c.LocalTime.Equal(c.RemoteTime) {
+				take = c.Newer()
+			} else {
+				take = resolve(c)
+			}
+		}
+		if take == Remote {
+			f.set(&out, r)
+			if at, ok := r.Modified[f.name]; ok {
+				out.Modified[f.name] = at
+			}
+		}
+	}
+	if len(out.Modified) == 0 {
+		out.Modified = nil
+	}
+	return out
+}
+
+// SyncState remembers, per peer, the last state both replicas agreed on
+type SyncState struct {
+	Path       string
+	Encryption *Encryption
+}
+
+// SyncStateFor returns the sync state that belongs to the task file at
+// dataPath
+func SyncStateFor(dataPath string) *SyncState {
+	return &SyncState{Path: SidecarPath(dataPath, "sync.json")}
+}
+
+type syncBase struct {
+	Time  time.Time `json:"time"`
+	Tasks []Task    `json:"tasks"`
+}
+
+// Base returns the last common state with peer, and when it was reached.
+// It is empty if the two have never synced.
+func (st *SyncState) Base(peer string) ([]Task, time.Time, error) {
+	all, err := st.load()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	b := all[peer]
+	return b.Tasks, b.Time, nil
+}
+
+// SetBase records tasks as the common state with peer
+func (st *SyncState) SetBase(peer string, tasks []Task, at time.Time) error {
+	all, err := st.load()
+	if err != nil {
+		return err
+	}
+	all[peer] = syncBase{Time: at, Tasks: tasks}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSealed(st.Path, data, st.Encryption)
+}
+
+func (st *SyncState) load() (map[string]syncBase, error) {
+	all := map[string]syncBase{}
+	data, err := readSealed(st.Path, st.Encryption)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return all, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &all)
+	return all, err
+}
+
+// Sync merges the local store with a replica of it (another copy of the
+// task file, kept in step by any file-sync tool), writes the result to
+// both, and remembers it as their new common state. peer identifies the
+// replica in state. Both stores stay locked for the whole sync. A remote
+// that changes while the sync runs (ErrRemoteChanged) is merged again.
+func Sync(local, remote Store, state *SyncState, peer string, resolve func(Conflict) Side) (res SyncResult, err error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		res, err = syncOnce(local, remote, state, peer, resolve)
+		if !errors.Is(err, ErrRemoteChanged) {
+			break
+		}
+	}
+	return res, err
+}
+
+func syncOnce(local, remote Store, state *SyncState, peer string, resolve func(Conflict) Side) (SyncResult, error) {
+	var res SyncResult
+	counting := func(c Conflict) Side {
+		res.Conflicts++
+		return resolve(c)
+	}
+	err := Modify(local, "sync", func(tasks []Task) ([]Task, error) {
+		var merged []Task
+		err := WithLock(remote, func() error {
+			base, _, err := state.Base(peer)
+			if err != nil {
+				return err
+			}
+			theirs, err := remote.Load()
+			if err != nil {
+				return err
+			}
+			assignUUIDs(theirs)
+			merged = MergeTasks(base, tasks, theirs, counting)
+			res.Pulled = countChanged(tasks, merged)
+			res.Pushed = countChanged(theirs, merged)
+			if len(DiffTasks(theirs, merged)) > 0 {
+				if err := remote.Save(merged); err != nil {
+					return fmt.Errorf("writing %s: %w", peer, err)
+				}
+			}
+			return state.SetBase(peer, cloneTasks(merged), time.Now())
+		})
+		return merged, err
+	})
+	return res, err
+}
+
+func indexByUUID(tasks []Task) map[string]Task {
+	by := make(map[string]Task, len(tasks))
+	for _, t := range tasks {
+		by[t.UUID] = t
+	}
+	return by
+}
+
+// countChanged counts the tasks added, deleted or with a field changed
+// going from before to after, ignoring display IDs and bookkeeping
+func countChanged(before, after []Task) int {
+	n := 0
+	old := indexByUUID(before)
+	for _, a := range after {
+		if b, ok := old[a.UUID]; !ok || !sameFields(b, a) {
+			n++
+		}
+		delete(old, a.UUID)
+	}
+	return n + len(old)
+}
+
+func sameFields(a, b Task) bool {
+	for _, f := range syncFields {
+		if !reflect.DeepEqual(f.get(a), f.get(b)) {
+			return false
+		}
+	}
+	return true
+}
+
+func lastModified(t Task) time.Time {
+	var last time.Time
+	for _, at := range t.Modified {
+		if at.After(last) {
+			last = at
+		}
+	}
+	return last
+}
+
+func copyModified(m map[string]time.Time) map[string]time.Time {
+	out := make(map[string]time.Time, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func formatField(v any) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return "(none)"
+		}
+		return v
+	case []string:
+		if len(v) == 0 {
+			return "(none)"
+		}
+		return strings.Join(v, ", ")
+	case [2]any:
+		if v[0] == true {
+			return "done"
+		}
+		return "pending"
+	case map[string]string:
+		if len(v) == 0 {
+			return "(none)"
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + "=" + v[k]
+		}
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,126 @@
+package todo
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeTasks(t *testing.T) {
+	task := func(n int, text string) Task {
+		return Task{ID: n, UUID: fmt.Sprintf("dddd0000-0000-4000-8000-%012d", n), Text: text}
+	}
+	withTags := func(t Task, tags ...string) Task {
+		t.Tags = tags
+		return t
+	}
+	withID := func(t Task, id int) Task {
+		t.ID = id
+		return t
+	}
+	at := func(t Task, field string, when time.Time) Task {
+		t.Modified = map[string]time.Time{field: when}
+		return t
+	}
+	earlier := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name                string
+		base, local, remote []Task
+		resolve             Side     // answer to every conflict
+		want                []Task   // compared by sameFields, UUID and ID
+		conflicts           []string // fields asked about
+	}{
+		{
+			name:   "changed on one side",
+			base:   []Task{task(1, "Buy milk")},
+			local:  []Task{task(1, "Buy milk")},
+			remote: []Task{task(1, "Buy oat milk")},
+			want:   []Task{task(1, "Buy oat milk")},
+		},
+		{
+			name:   "different fields changed on each side",
+			base:   []Task{task(1, "Buy milk")},
+			local:  []Task{task(1, "Buy oat milk")},
+			remote: []Task{withTags(task(1, "Buy milk"), "shop")},
+			want:   []Task{withTags(task(1, "Buy oat milk"), "shop")},
+		},
+		{
+			name:      "same field changed on both sides",
+			base:      []Task{task(1, "Buy milk")},
+			local:     []Task{task(1, "Buy oat milk")},
+			remote:    []Task{task(1, "Buy soy milk")},
+			resolve:   Remote,
+			want:      []Task{task(1, "Buy soy milk")},
+			conflicts: []string{"text"},
+		},
+		{
+			name:   "added on both sides with the same ID",
+			base:   []Task{task(1, "Buy milk")},
+			local:  []Task{task(1, "Buy milk"), task(2, "Call mom")},
+			remote: []Task{task(1, "Buy milk"), withID(task(3, "Pay rent"), 2)},
+			want:   []Task{task(1, "Buy milk"), task(2, "Call mom"), withID(task(3, "Pay rent"), 3)},
+		},
+		{
+			name:   "deleted on one side, untouched on the other",
+			base:   []Task{task(1, "Buy milk"), task(2, "Call mom")},
+			local:  []Task{task(1, "Buy milk"), task(2, "Call mom")},
+			remote: []Task{task(2, "Call mom")},
+			want:   []Task{task(2, "Call mom")},
+		},
+		{
+			name:      "deleted remotely, edited locally",
+			base:      []Task{task(1, "Buy milk")},
+			local:     []Task{task(1, "Buy oat milk")},
+			remote:    []Task{},
+			resolve:   Local,
+			want:      []Task{task(1, "Buy oat milk")},
+			conflicts: []string{"deleted"},
+		},
+		{
+			name:      "deleted locally, edited remotely",
+			base:      []Task{task(1, "Buy milk")},
+			local:     []Task{},
+			remote:    []Task{task(1, "Buy oat milk")},
+			resolve:   Local,
+			want:      []Task{},
+			conflicts: []string{"deleted"},
+		},
+		{
+			name:   "no common state: the newer change wins",
+			local:  []Task{at(task(1, "Buy oat milk"), "text", later)},
+			remote: []Task{at(task(1, "Buy soy milk"), "text", earlier)},
+			want:   []Task{task(1, "Buy oat milk")},
+		},
+		{
+			name:      "no common state and no times: asked",
+			local:     []Task{task(1, "Buy oat milk")},
+			remote:    []Task{task(1, "Buy soy milk")},
+			resolve:   Remote,
+			want:      []Task{task(1, "Buy soy milk")},
+			conflicts: []string{"text"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []string
+			got := MergeTasks(tt.base, tt.local, tt.remote, func(c Conflict) Side {
+				asked = append(asked, c.Field)
+				return tt.resolve
+			})
+			if len(got) != len(tt.want) {
+				t.Fatalf("merged %+v\nwant %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if !sameFields(got[i], tt.want[i]) || got[i].UUID != tt.want[i].UUID || got[i].ID != tt.want[i].ID {
+					t.Errorf("task %d = %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+			if !reflect.DeepEqual(asked, tt.conflicts) {
+				t.Errorf("asked about %q, want %q", asked, tt.conflicts)
+			}
+		})
+	}
+}
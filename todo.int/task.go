@@ -1,8 +1,11 @@
 package todo
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -12,34 +15,50 @@ import (
 
 // Task struct represents a single task
 type Task struct {
-	ID        int      `json:"id"`
-	Text      string   `json:"text"`
-	Completed bool     `json:"completed"`
-	DueDate   string   `json:"due_date,omitempty"`
-	Tags      []string `json:"tags,omitempty"`
-	Priority  string   `json:"priority,omitempty"`
-	Recurring string   `json:"recurring,omitempty"`
+	ID          int      `json:"id"`
+	UUID        string   `json:"uuid,omitempty"`
+	Text        string   `json:"text"`
+	Completed   bool     `json:"completed"`
+	CompletedAt string   `json:"completed_at,omitempty"`
+	DueDate     string   `json:"due_date,omitempty"`
+	DueTime     string   `json:"due_time,omitempty"` // HH:MM, local time
+	Duration    string   `json:"duration,omitempty"` // e.g. 1h30m, from DueTime
+	Tags        []string `json:"tags,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	Recurring   string   `json:"recurring,omitempty"`
+	Parent      string   `json:"parent,omitempty"` // UUID of the task this is a subtask of
+	Notes       []Note   `json:"notes,omitempty"`
+
+	// Modified holds when each field last changed, for merging replicas
+	Modified map[string]time.Time `json:"modified,omitempty"`
+
+	// Extra holds attributes this version doesn't know about, so they
+	// survive a load/save round trip
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-// AddTaskWithDueDate adds a task with an optional due date
-func AddTaskWithDueDate(text, due string) error {
-	tasks, _ := LoadTasks()
-	parsed := ""
-	if due != "" {
-		dt, err := parseNaturalDate(due)
-		if err != nil {
-			return err
-		}
-		parsed = dt
+// Note is a dated comment on a task, like a Taskwarrior annotation
+type Note struct {
+	At   string `json:"at"` // RFC 3339
+	Text string `json:"text"`
+}
+
+// AddTaskWithDueDate adds a task with an optional due date, which may
+// include a time and duration ("friday @ 18:00 for 1h")
+func AddTaskWithDueDate(s Store, text, due string) error {
+	newTask := Task{UUID: newUUID(), Text: text, Completed: false}
+	if err := newTask.SetDue(due); err != nil {
+		return err
 	}
-	newTask := Task{ID: len(tasks) + 1, Text: text, Completed: false, DueDate: parsed}
-	tasks = append(tasks, newTask)
-	return SaveTasks(tasks)
+	return Modify(s, "add", func(tasks []Task) ([]Task, error) {
+		newTask.ID = NextID(tasks)
+		return append(tasks, newTask), nil
+	})
 }
 
 // ListTasks displays all tasks
-func ListTasks() {
-	tasks, _ := LoadTasks()
+func ListTasks(s Store) {
+	tasks, _ := s.Load()
 	if len(tasks) == 0 {
 		color.Yellow("📭 No tasks available.")
 		return
@@ -52,7 +71,7 @@ func ListTasks() {
 		}
 
 		if task.DueDate != "" {
-			status += color.MagentaString(" (Due: %s)", task.DueDate)
+			status += color.MagentaString(" (Due: %s)", task.DueLabel())
 		}
 
 		fmt.Println(status)
@@ -71,7 +90,7 @@ type ListFilterOptions struct {
 
 // task.go
 func FilterTasks(tasks []Task, options ListFilterOptions) []Task {
-	var filtered []Task
+	filtered := []Task{}
 	today := time.Now().Format("2006-01-02")
 
 	for _, task := range tasks {
@@ -87,30 +106,117 @@ func FilterTasks(tasks []Task, options ListFilterOptions) []Task {
 		if options.OverdueOnly && !IsOverdue(task.DueDate) {
 			continue
 		}
+		if options.Tag != "" && !hasTag(task.Tags, options.Tag) {
+			continue
+		}
+		if options.Priority != "" && strings.ToLower(task.Priority) != options.Priority {
+			continue
+		}
 		filtered = append(filtered, task)
 	}
 	return filtered
 }
 
-// MarkTaskDone marks a task as completed
-func MarkTaskDone(input string) error {
-	tasks, _ := LoadTasks()
-	found := false
+// Querier is implemented by stores that can apply list filters natively
+type Querier interface {
+	Query(options ListFilterOptions) ([]Task, error)
+}
 
-	id, err := strconv.Atoi(input)
-	for i, task := range tasks {
-		if (err == nil && task.ID == id) || task.Text == input {
-			tasks[i].Completed = true
-			found = true
-			break
+// QueryTasks returns the tasks matching options, letting the store do the
+// filtering when it knows how
+func QueryTasks(s Store, options ListFilterOptions) ([]Task, error) {
+	if q, ok := s.(Querier); ok {
+		return q.Query(options)
+	}
+	tasks, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterTasks(tasks, options), nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
 		}
 	}
+	return false
+}
 
-	if !found {
-		return fmt.Errorf("task not found")
+// MarkTaskDone marks a task as completed
+func MarkTaskDone(s Store, input string) error {
+	return Modify(s, "done", func(tasks []Task) ([]Task, error) {
+		i, err := ResolveTask(tasks, input)
+		if err != nil {
+			return nil, err
+		}
+		tasks[i].SetCompleted(true)
+		return tasks, nil
+	})
+}
+
+// UpdateTask applies fn to the task with the given ID inside a locked
+// load-modify-save cycle. op names the change, as for Modify.
+func UpdateTask(s Store, op string, id int, fn func(t *Task)) error {
+	return Modify(s, op, func(tasks []Task) ([]Task, error) {
+		for i := range tasks {
+			if tasks[i].ID == id {
+				fn(&tasks[i])
+				return tasks, nil
+			}
+		}
+		return nil, ErrTaskNotFound
+	})
+}
+
+// ErrConflict is returned when a task changed since the caller last saw it
+var ErrConflict = errors.New("task was changed elsewhere")
+
+// ModifySeen runs fn on the stored copy of seen (matched by UUID) inside a
+// locked load-modify-save cycle, but only while it is exactly as the caller
+// last saw it. A task edited elsewhere in the meantime gives ErrConflict and
+// a deleted one ErrTaskNotFound, instead of overwriting that change. i is
+// the task's index in tasks.
+func ModifySeen(s Store, op string, seen Task, fn func(tasks []Task, i int) []Task) error {
+	return Modify(s, op, func(tasks []Task) ([]Task, error) {
+		for i := range tasks {
+			if tasks[i].UUID != seen.UUID {
+				continue
+			}
+			if !reflect.DeepEqual(tasks[i], seen) {
+				return nil, ErrConflict
+			}
+			return fn(tasks, i), nil
+		}
+		return nil, ErrTaskNotFound
+	})
+}
+
+// ToggleTaskDone flips a task between done and pending
+func ToggleTaskDone(s Store, id int) error {
+	return UpdateTask(s, "toggle", id, func(t *Task) { t.SetCompleted(!t.Completed) })
+}
+
+// SetCompleted marks the task done or pending, stamping when it was done
+func (t *Task) SetCompleted(done bool) {
+	if done && !t.Completed {
+		t.CompletedAt = time.Now().UTC().Format(time.RFC3339)
 	}
+	if !done {
+		t.CompletedAt = ""
+	}
+	t.Completed = done
+}
 
-	return SaveTasks(tasks)
+// CompletedTime parses CompletedAt. ok is false for pending tasks and for
+// tasks completed before completion times were recorded.
+func (t Task) CompletedTime() (at time.Time, ok bool) {
+	if !t.Completed || t.CompletedAt == "" {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, t.CompletedAt)
+	return at, err == nil
 }
 
 // parseNaturalDate handles natural language date inputs
@@ -161,78 +267,136 @@ func parseNaturalDate(input string) (string, error) {
 	}
 }
 
-// SetDueDate assigns a due date to a task
-func SetDueDate(input string, dueDate string) error {
-	tasks, _ := LoadTasks()
-	found := false
-
-	parsedDate, err := parseNaturalDate(dueDate)
-	if err != nil {
+// SetDueDate assigns a due date, with an optional time and duration, to a
+// task
+func SetDueDate(s Store, input string, dueDate string) error {
+	var due Task
+	if err := due.SetDue(dueDate); err != nil {
 		return err
 	}
+	if due.DueDate == "" {
+		return fmt.Errorf("invalid date format or unsupported natural keyword")
+	}
 
-	id, err := strconv.Atoi(input)
-	for i, task := range tasks {
-		if (err == nil && task.ID == id) || task.Text == input {
-			tasks[i].DueDate = parsedDate
-			found = true
-			break
+	return Modify(s, "due", func(tasks []Task) ([]Task, error) {
+		i, err := ResolveTask(tasks, input)
+		if err != nil {
+			return nil, err
 		}
-	}
+		tasks[i].DueDate, tasks[i].DueTime, tasks[i].Duration = due.DueDate, due.DueTime, due.Duration
+		return tasks, nil
+	})
+}
 
-	if !found {
-		return fmt.Errorf("task not found")
+// SetDue parses a due date like "tomorrow" or "friday @ 18:00 for 1h" into
+// DueDate, DueTime and Duration. An empty input clears all three.
+func (t *Task) SetDue(input string) error {
+	if strings.TrimSpace(input) == "" {
+		t.DueDate, t.DueTime, t.Duration = "", "", ""
+		return nil
 	}
-
-	return SaveTasks(tasks)
+	date, at, dur, err := ParseDateTimeDuration(input)
+	if err != nil {
+		return err
+	}
+	if dur != "" && at == "" {
+		return fmt.Errorf("a duration needs a time: %s", input)
+	}
+	if dur != "" {
+		d, _ := time.ParseDuration(dur)
+		dur = shortDuration(d)
+	}
+	if at != "" {
+		clock, _ := time.Parse("15:04", at)
+		at = clock.Format("15:04")
+	}
+	t.DueDate, t.DueTime, t.Duration = date, at, dur
+	return nil
 }
 
-// DeleteTask removes a task by ID or text
-func DeleteTask(input string) error {
-	tasks, _ := LoadTasks()
-	newTasks := []Task{}
-	found := false
+// DueAt is when a task with a due time is due. ok is false for tasks
+// without one.
+func (t Task) DueAt() (at time.Time, ok bool) {
+	if t.DueDate == "" || t.DueTime == "" {
+		return time.Time{}, false
+	}
+	at, err := time.ParseInLocation("2006-01-02 15:04", t.DueDate+" "+t.DueTime, time.Local)
+	return at, err == nil
+}
 
-	id, err := strconv.Atoi(input)
-	for _, task := range tasks {
-		if (err == nil && task.ID == id) || task.Text == input {
-			found = true
-			continue
-		}
-		newTasks = append(newTasks, task)
+// DueLabel is the due date as shown in lists: "2026-10-20", or
+// "2026-10-20 18:00 for 1h" with a time and duration
+func (t Task) DueLabel() string {
+	label := t.DueDate
+	if t.DueTime != "" {
+		label += " " + t.DueTime
+	}
+	if t.Duration != "" {
+		label += " for " + t.Duration
 	}
+	return label
+}
 
-	if !found {
-		return fmt.Errorf("task not found")
+// shortDuration formats d like "1h30m", without the zero units
+// time.Duration.String adds
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
 	}
+	return s
+}
+
+// DeleteTask removes a task by ID, text or UUID prefix. Stores tracked
+// with a Trash keep it there until it is restored or purged.
+func DeleteTask(s Store, input string) error {
+	return Modify(s, "delete", func(tasks []Task) ([]Task, error) {
+		i, err := ResolveTask(tasks, input)
+		if err != nil {
+			return nil, err
+		}
+		return append(tasks[:i], tasks[i+1:]...), nil
+	})
+}
 
-	return SaveTasks(newTasks)
+// DeleteTasks removes several tasks in one operation, so a bulk delete is
+// undone (and backed up) as a unit
+func DeleteTasks(s Store, inputs []string) error {
+	return Modify(s, "delete", func(tasks []Task) ([]Task, error) {
+		for _, input := range inputs {
+			i, err := ResolveTask(tasks, input)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", input, err)
+			}
+			tasks = append(tasks[:i], tasks[i+1:]...)
+		}
+		return tasks, nil
+	})
 }
 
 // EditTaskText updates a task's text
-func EditTaskText(idOrText, newText string) error {
-	tasks, err := LoadTasks()
-	if err != nil {
-		return err
-	}
-	id, idErr := strconv.Atoi(idOrText)
-	updated := false
-	for i := range tasks {
-		if (idErr == nil && tasks[i].ID == id) || tasks[i].Text == idOrText {
-			tasks[i].Text = newText
-			updated = true
-			break
+func EditTaskText(s Store, idOrText, newText string) error {
+	return Modify(s, "edit", func(tasks []Task) ([]Task, error) {
+		i, err := ResolveTask(tasks, idOrText)
+		if err != nil {
+			return nil, err
 		}
-	}
-	if !updated {
-		return fmt.Errorf("task not found")
-	}
-	return SaveTasks(tasks)
+		tasks[i].Text = newText
+		return tasks, nil
+	})
+}
+
+// SetTags replaces a task's tags
+func SetTags(s Store, id int, tags []string) error {
+	return UpdateTask(s, "tag", id, func(t *Task) { t.Tags = tags })
 }
 
 // SearchTasks prints tasks that match the keyword
-func SearchTasks(keyword string) {
-	tasks, err := LoadTasks()
+func SearchTasks(s Store, keyword string) {
+	tasks, err := s.Load()
 	if err != nil {
 		fmt.Println("Error loading tasks:", err)
 		return
@@ -249,9 +413,11 @@ func SearchTasks(keyword string) {
 	}
 }
 
-// ClearTasks deletes all tasks
-func ClearTasks() error {
-	return SaveTasks([]Task{})
+// ClearTasks deletes all tasks (into the trash, if the store keeps one)
+func ClearTasks(s Store) error {
+	return Modify(s, "clear", func([]Task) ([]Task, error) {
+		return []Task{}, nil
+	})
 }
 
 // SelectTaskFzf allows user to choose a single task
@@ -0,0 +1,367 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Taskwarrior (taskwarrior.org) `task export` / `task import` mapping:
+//
+//	uuid         UUID, kept, so importing again updates instead of adding
+//	description  Text
+//	status       Completed ("completed"); "deleted" tasks are skipped
+//	end          CompletedAt
+//	due          DueDate, and DueTime unless it is midnight
+//	tags         Tags
+//	priority     Priority: H high, M medium, L low
+//	recur        Recurring: weekly, 2d, weekdays, ...
+//	annotations  Notes
+//
+// Recurring tasks are a template (status "recurring") and an instance per
+// occurrence; an import keeps the template, due when the next pending
+// instance is, and the completed instances. What has no Taskwarrior
+// attribute goes out as todo_priority, todo_recurring, todo_duration and
+// todo_parent, which Taskwarrior keeps as it would any unknown attribute.
+// Other attributes (project, entry, scheduled, ...) are kept in Task.Extra
+// under "taskwarrior" and written back out.
+
+const taskwarriorTime = "20060102T150405Z"
+
+var taskwarriorRecur = regexp.MustCompile(`^(\d+)\s*(d|days?|w|wks?|weeks?|mo|mos|months?|q|qtrs?|quarters?|y|yrs?|years?)$`)
+
+// taskwarriorFields are the attributes decoded into Task fields, or
+// computed by Taskwarrior, so not kept in Extra
+var taskwarriorFields = map[string]bool{
+	"id": true, "urgency": true, "uuid": true, "description": true, "status": true,
+	"end": true, "due": true, "tags": true, "priority": true, "recur": true,
+	"annotations": true, "todo_priority": true, "todo_recurring": true,
+	"todo_duration": true, "todo_parent": true,
+}
+
+type taskwarriorAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// EncodeTaskwarrior writes tasks as a `task export` JSON array, one task per
+// line
+func EncodeTaskwarrior(tasks []Task) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("[\n")
+	for i, t := range tasks {
+		line, err := json.Marshal(taskwarriorTask(t))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(line)
+		if i < len(tasks)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("]\n")
+	return b.Bytes(), nil
+}
+
+func taskwarriorTask(t Task) map[string]any {
+	tw := map[string]any{}
+	if raw, ok := t.Extra["taskwarrior"]; ok {
+		var extra map[string]json.RawMessage
+		if json.Unmarshal(raw, &extra) == nil {
+			for k, v := range extra {
+				tw[k] = v
+			}
+		}
+	}
+
+	if t.UUID != "" {
+		tw["uuid"] = t.UUID
+	}
+	tw["description"] = t.Text
+	tw["status"] = "pending"
+	if t.Completed {
+		tw["status"] = "completed"
+		if at, ok := t.CompletedTime(); ok {
+			tw["end"] = at.UTC().Format(taskwarriorTime)
+		}
+	}
+	if t.DueDate != "" {
+		at, ok := t.DueAt()
+		if !ok {
+			at, _ = time.ParseInLocation("2006-01-02", t.DueDate, time.Local)
+		}
+		tw["due"] = at.UTC().Format(taskwarriorTime)
+	}
+	if t.Duration != "" {
+		tw["todo_duration"] = t.Duration
+	}
+	if len(t.Tags) > 0 {
+		tags := make([]string, 0, len(t.Tags))
+		for _, tag := range t.Tags {
+			tags = append(tags, strings.Join(strings.Fields(tag), "_"))
+		}
+		tw["tags"] = tags
+	}
+	switch strings.ToLower(t.Priority) {
+	case "":
+	case "high":
+		tw["priority"] = "H"
+	case "medium":
+		tw["priority"] = "M"
+	case "low":
+		tw["priority"] = "L"
+	default:
+		tw["todo_priority"] = t.Priority
+	}
+	if t.Recurring != "" {
+		// Taskwarrior only repeats tasks with a due date, on its own rules
+		if recur := taskwarriorRecurrence(t.Recurring); recur != "" && t.DueDate != "" {
+			tw["recur"] = recur
+			if !t.Completed {
+				tw["status"] = "recurring"
+			}
+		} else {
+			tw["todo_recurring"] = t.Recurring
+		}
+	}
+	if t.Parent != "" {
+		tw["todo_parent"] = t.Parent
+	}
+	if len(t.Notes) > 0 {
+		notes := make([]taskwarriorAnnotation, 0, len(t.Notes))
+		for _, n := range t.Notes {
+			entry := n.At
+			if at, err := time.Parse(time.RFC3339, n.At); err == nil {
+				entry = at.UTC().Format(taskwarriorTime)
+			}
+			notes = append(notes, taskwarriorAnnotation{entry, n.Text})
+		}
+		tw["annotations"] = notes
+	}
+	return tw
+}
+
+// DecodeTaskwarrior reads `task export` output: a JSON array, or one task
+// object per line
+func DecodeTaskwarrior(data []byte) ([]Task, error) {
+	var objects []map[string]json.RawMessage
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &objects); err != nil {
+			return nil, err
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for dec.More() {
+			var obj map[string]json.RawMessage
+			if err := dec.Decode(&obj); err != nil {
+				return nil, err
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	// Pending instances of a recurring task in the file are folded into its
+	// template, which is due when the first of them is
+	templates := map[string]bool{}
+	for _, obj := range objects {
+		if twString(obj, "status") == "recurring" {
+			templates[twString(obj, "uuid")] = true
+		}
+	}
+	nextDue := map[string]string{}
+	for _, obj := range objects {
+		parent, due := twString(obj, "parent"), twString(obj, "due")
+		if templates[parent] && twString(obj, "status") == "pending" && due != "" {
+			if next, ok := nextDue[parent]; !ok || due < next {
+				nextDue[parent] = due
+			}
+		}
+	}
+
+	var tasks []Task
+	for _, obj := range objects {
+		status, parent := twString(obj, "status"), twString(obj, "parent")
+		instance := templates[parent]
+		switch {
+		case status == "deleted":
+			continue
+		case instance && status != "completed":
+			continue
+		case status == "recurring" && nextDue[twString(obj, "uuid")] != "":
+			obj["due"], _ = json.Marshal(nextDue[twString(obj, "uuid")])
+		}
+		t, err := taskwarriorToTask(obj, instance)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", twString(obj, "uuid"), err)
+		}
+		t.ID = len(tasks) + 1
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// taskwarriorToTask maps one task object. A completed instance of a
+// recurring task loses its recurrence, which stays with the template.
+func taskwarriorToTask(obj map[string]json.RawMessage, instance bool) (Task, error) {
+	t := Task{
+		UUID:     twString(obj, "uuid"),
+		Text:     twString(obj, "description"),
+		Priority: twString(obj, "todo_priority"),
+		Parent:   twString(obj, "todo_parent"),
+	}
+	if t.UUID == "" {
+		t.UUID = newUUID()
+	}
+	if twString(obj, "status") == "completed" {
+		t.Completed = true
+		if at, err := time.Parse(taskwarriorTime, twString(obj, "end")); err == nil {
+			t.CompletedAt = at.UTC().Format(time.RFC3339)
+		}
+	}
+	if due := twString(obj, "due"); due != "" {
+		at, err := time.Parse(taskwarriorTime, due)
+		if err != nil {
+			return t, fmt.Errorf("invalid due %q", due)
+		}
+		at = at.Local()
+		t.DueDate = at.Format("2006-01-02")
+		if at.Hour() != 0 || at.Minute() != 0 {
+			t.DueTime = at.Format("15:04")
+			if d, err := time.ParseDuration(twString(obj, "todo_duration")); err == nil && d > 0 {
+				t.Duration = shortDuration(d)
+			}
+		}
+	}
+	if raw, ok := obj["tags"]; ok {
+		if err := json.Unmarshal(raw, &t.Tags); err != nil {
+			return t, fmt.Errorf("invalid tags: %w", err)
+		}
+	}
+	switch twString(obj, "priority") {
+	case "H":
+		t.Priority = "high"
+	case "M":
+		t.Priority = "medium"
+	case "L":
+		t.Priority = "low"
+	}
+	if !instance {
+		t.Recurring = twString(obj, "todo_recurring")
+		if recur := twString(obj, "recur"); recur != "" {
+			t.Recurring = recurrenceFromTaskwarrior(recur)
+		}
+	}
+	if raw, ok := obj["annotations"]; ok {
+		var notes []taskwarriorAnnotation
+		if err := json.Unmarshal(raw, &notes); err != nil {
+			return t, fmt.Errorf("invalid annotations: %w", err)
+		}
+		for _, n := range notes {
+			at := n.Entry
+			if parsed, err := time.Parse(taskwarriorTime, n.Entry); err == nil {
+				at = parsed.UTC().Format(time.RFC3339)
+			}
+			t.Notes = append(t.Notes, Note{At: at, Text: n.Description})
+		}
+	}
+
+	extra := map[string]json.RawMessage{}
+	for k, v := range obj {
+		skip := taskwarriorFields[k] || (instance && (k == "parent" || k == "imask" || k == "mask"))
+		if !skip {
+			extra[k] = v
+		}
+	}
+	if len(extra) > 0 {
+		raw, err := json.Marshal(extra)
+		if err != nil {
+			return t, err
+		}
+		t.Extra = map[string]json.RawMessage{"taskwarrior": raw}
+	}
+	return t, nil
+}
+
+// twString is a string attribute of a task object, or ""
+func twString(obj map[string]json.RawMessage, key string) string {
+	var s string
+	if raw, ok := obj[key]; ok {
+		_ = json.Unmarshal(raw, &s)
+	}
+	return s
+}
+
+// recurrenceFromTaskwarrior turns recur:weekly into "weekly", recur:2d into
+// "every 2 days" and so on. Other rules are kept as they are.
+func recurrenceFromTaskwarrior(recur string) string {
+	switch strings.ToLower(recur) {
+	case "daily", "day":
+		return "daily"
+	case "weekly", "week":
+		return "weekly"
+	case "monthly", "month":
+		return "monthly"
+	case "yearly", "year", "annual", "annually":
+		return "yearly"
+	case "weekdays":
+		return "every weekday"
+	case "biweekly", "fortnight":
+		return "every 2 weeks"
+	case "quarterly":
+		return "every 3 months"
+	case "semiannual":
+		return "every 6 months"
+	}
+	m := taskwarriorRecur.FindStringSubmatch(strings.ToLower(recur))
+	if m == nil {
+		return recur
+	}
+	n, _ := strconv.Atoi(m[1])
+	unit := "days"
+	switch m[2][0] {
+	case 'w':
+		unit = "weeks"
+	case 'm':
+		unit = "months"
+	case 'q':
+		n, unit = n*3, "months"
+	case 'y':
+		unit = "years"
+	}
+	if n == 1 {
+		return map[string]string{"days": "daily", "weeks": "weekly", "months": "monthly", "years": "yearly"}[unit]
+	}
+	return fmt.Sprintf("every %d %s", n, unit)
+}
+
+// taskwarriorRecurrence is the recur value for a recurrence, or "" when
+// Taskwarrior has no equivalent
+func taskwarriorRecurrence(rec string) string {
+	switch rec {
+	case "daily", "every day":
+		return "daily"
+	case "weekly", "every week":
+		return "weekly"
+	case "monthly", "every month":
+		return "monthly"
+	case "yearly", "every year":
+		return "yearly"
+	case "every weekday":
+		return "weekdays"
+	}
+	if fields := strings.Fields(rec); len(fields) == 3 && fields[0] == "every" {
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			units := map[string]string{"days": "d", "weeks": "w", "months": "mo", "years": "y"}
+			if unit, ok := units[fields[2]]; ok {
+				return fields[1] + unit
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package todo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTaskwarriorRoundTrip(t *testing.T) {
+	project, _ := json.Marshal(map[string]string{"project": "home", "entry": "20261001T080000Z"})
+	tasks := []Task{
+		{
+			ID: 1, UUID: "bbbb0000-0000-4000-8000-000000000001", Text: "Fix the fence",
+			DueDate: "2026-10-20", Tags: []string{"garden"}, Priority: "high",
+			Notes: []Note{{At: "2026-10-02T10:00:00Z", Text: "Buy nails first"}},
+			Extra: map[string]json.RawMessage{"taskwarrior": project},
+		},
+		{
+			ID: 2, UUID: "bbbb0000-0000-4000-8000-000000000002", Text: "Team sync",
+			DueDate: "2026-10-21", DueTime: "09:30", Duration: "45m", Recurring: "weekly",
+		},
+		{
+			ID: 3, UUID: "bbbb0000-0000-4000-8000-000000000003", Text: "Nail the boards",
+			Parent: "bbbb0000-0000-4000-8000-000000000001", Priority: "urgent",
+			Recurring: "every last friday",
+		},
+		{
+			ID: 4, UUID: "bbbb0000-0000-4000-8000-000000000004", Text: "Order wood",
+			Completed: true, CompletedAt: "2026-10-03T16:20:00Z", Priority: "low",
+		},
+	}
+
+	data, err := EncodeTaskwarrior(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeTaskwarrior(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tasks) {
+		t.Fatalf("decoded %d tasks, want %d:\n%s", len(got), len(tasks), data)
+	}
+	for i := range tasks {
+		if !sameFields(got[i], tasks[i]) || got[i].UUID != tasks[i].UUID || got[i].Duration != tasks[i].Duration {
+			t.Errorf("task %d came back as\n  %+v\nwant\n  %+v", i+1, got[i], tasks[i])
+		}
+		if got[i].Modified != nil {
+			t.Errorf("task %d has modification times %v, want none", i+1, got[i].Modified)
+		}
+	}
+}
+
+func TestDecodeTaskwarriorRecurring(t *testing.T) {
+	export := `{"uuid":"cccc0000-0000-4000-8000-000000000001","description":"Water plants","status":"recurring","recur":"2d","due":"20261001T000000Z"}
+{"uuid":"cccc0000-0000-4000-8000-000000000002","description":"Water plants","status":"completed","parent":"cccc0000-0000-4000-8000-000000000001","due":"20261001T000000Z","end":"20261001T180000Z","imask":0}
+{"uuid":"cccc0000-0000-4000-8000-000000000003","description":"Water plants","status":"pending","parent":"cccc0000-0000-4000-8000-000000000001","due":"20261005T000000Z","imask":2}
+{"uuid":"cccc0000-0000-4000-8000-000000000004","description":"Water plants","status":"pending","parent":"cccc0000-0000-4000-8000-000000000001","due":"20261003T000000Z","imask":1}
+{"uuid":"cccc0000-0000-4000-8000-000000000005","description":"Old idea","status":"deleted"}
+`
+	tasks, err := DecodeTaskwarrior([]byte(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("decoded %d tasks, want the template and the completed instance", len(tasks))
+	}
+	template, done := tasks[0], tasks[1]
+	if template.Recurring != "every 2 days" || template.Completed {
+		t.Errorf("template = %+v, want pending, every 2 days", template)
+	}
+	next, _ := time.Parse(taskwarriorTime, "20261003T000000Z")
+	if want := next.Local().Format("2006-01-02"); template.DueDate != want {
+		t.Errorf("template due %s, want the next instance's %s", template.DueDate, want)
+	}
+	if !done.Completed || done.Recurring != "" || done.CompletedAt != "2026-10-01T18:00:00Z" {
+		t.Errorf("completed instance = %+v", done)
+	}
+	if _, ok := done.Extra["taskwarrior"]; ok {
+		t.Errorf("instance kept %s, want parent and imask dropped", done.Extra["taskwarrior"])
+	}
+}
@@ -0,0 +1,80 @@
+package todo
+
+import (
+	"time"
+)
+
+// EndOfDay parses a date the way ParseNaturalDate does and returns the
+// last instant of that day in local time, so "as of friday" includes
+// everything that happened on Friday
+func EndOfDay(input string) (time.Time, error) {
+	date, err := ParseNaturalDate(input)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// StateAt rebuilds the task list as it stood at t by replaying only the
+// journal events up to that moment
+func (j *Journal) StateAt(t time.Time) ([]Task, error) {
+	events, err := j.Events()
+	if err != nil {
+		return nil, err
+	}
+	var upTo []Event
+	for _, e := range events {
+		if e.Time.After(t) {
+			break
+		}
+		upTo = append(upTo, e)
+	}
+	return ReplayEvents(upTo), nil
+}
+
+// Start returns the time of the first journal event, or the zero time if
+// the journal is empty
+func (j *Journal) Start() (time.Time, error) {
+	events, err := j.Events()
+	if err != nil || len(events) == 0 {
+		return time.Time{}, err
+	}
+	return events[0].Time, nil
+}
+
+// SnapshotDiff sums up how the list changed between two points in time
+type SnapshotDiff struct {
+	Added     []Task
+	Completed []Task
+	Deleted   []Task
+	Redated   []TaskDiff
+}
+
+// CompareSnapshots works out what was added, completed, deleted or
+// re-dated going from the before list to the after list
+func CompareSnapshots(before, after []Task) SnapshotDiff {
+	var out SnapshotDiff
+	for _, d := range DiffTasks(before, after) {
+		switch {
+		case d.Before == nil:
+			out.Added = append(out.Added, *d.After)
+			if d.After.Completed {
+				out.Completed = append(out.Completed, *d.After)
+			}
+		case d.After == nil:
+			out.Deleted = append(out.Deleted, *d.Before)
+		default:
+			if !d.Before.Completed && d.After.Completed {
+				out.Completed = append(out.Completed, *d.After)
+			}
+			if d.Before.DueDate != d.After.DueDate {
+				out.Redated = append(out.Redated, d)
+			}
+		}
+	}
+	return out
+}
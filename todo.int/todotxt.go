@@ -0,0 +1,266 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todo.txt (github.com/todotxt/todo.txt) mapping:
+//
+//	Completed  "x " prefix, followed by the completion date
+//	Priority   "(A) " prefix: high A, medium B, low C; other letters as is.
+//	           Done tasks keep it as pri:A, as the format drops the prefix.
+//	Tags       +project and @context (other tags, #tag too, get a +)
+//	DueDate    due:2006-01-02
+//	Recurring  rec:1w, rec:3d, ...; other rules with _ for spaces
+//
+// The creation date and key:value pairs with no Task field are kept in
+// Task.Extra under "todotxt" and written back out.
+
+var (
+	todoTxtDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoTxtPriority = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtRec      = regexp.MustCompile(`^(\+?)(\d+)([dwmyb])$`)
+	todoTxtKey      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+)
+
+// todoTxtExtra is what Task.Extra["todotxt"] holds
+type todoTxtExtra struct {
+	Created string   `json:"created,omitempty"`
+	Keys    []string `json:"keys,omitempty"` // key:value, in order
+}
+
+// EncodeTodoTxt writes tasks in todo.txt format, one per line
+func EncodeTodoTxt(tasks []Task) ([]byte, error) {
+	var b bytes.Buffer
+	for _, t := range tasks {
+		b.WriteString(todoTxtLine(t))
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+func todoTxtLine(t Task) string {
+	var extra todoTxtExtra
+	if raw, ok := t.Extra["todotxt"]; ok {
+		_ = json.Unmarshal(raw, &extra)
+	}
+
+	var parts []string
+	pri := todoTxtPriorityLetter(t.Priority)
+	if t.Completed {
+		parts = append(parts, "x")
+		// The creation date can only follow a completion date; without
+		// one it is written alone rather than made up as both
+		if at, ok := t.CompletedTime(); ok {
+			parts = append(parts, at.Local().Format("2006-01-02"))
+		}
+		if extra.Created != "" {
+			parts = append(parts, extra.Created)
+		}
+	} else {
+		if pri != "" {
+			parts = append(parts, "("+pri+")")
+		}
+		if extra.Created != "" {
+			parts = append(parts, extra.Created)
+		}
+	}
+
+	parts = append(parts, strings.Fields(t.Text)...)
+	for _, tag := range t.Tags {
+		tag = strings.Join(strings.Fields(tag), "_")
+		switch {
+		case tag == "" || tag == "+" || tag == "@":
+			continue
+		case strings.HasPrefix(tag, "#"):
+			tag = "+" + tag[1:]
+		case !strings.HasPrefix(tag, "+") && !strings.HasPrefix(tag, "@"):
+			tag = "+" + tag
+		}
+		parts = append(parts, tag)
+	}
+	if t.DueDate != "" {
+		parts = append(parts, "due:"+t.DueDate)
+	}
+	if t.Recurring != "" {
+		parts = append(parts, "rec:"+todoTxtRecurrence(t.Recurring))
+	}
+	switch {
+	case t.Priority != "" && pri == "":
+		parts = append(parts, "pri:"+strings.Join(strings.Fields(t.Priority), "_"))
+	case t.Completed && pri != "":
+		parts = append(parts, "pri:"+pri)
+	}
+	parts = append(parts, extra.Keys...)
+	return strings.Join(parts, " ")
+}
+
+// DecodeTodoTxt reads a todo.txt file. Blank lines are skipped. Tasks get
+// display IDs in file order.
+func DecodeTodoTxt(data []byte) ([]Task, error) {
+	var tasks []Task
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		t := parseTodoTxtLine(line)
+		t.ID = len(tasks) + 1
+		tasks = append(tasks, t)
+	}
+	return tasks, sc.Err()
+}
+
+func parseTodoTxtLine(line string) Task {
+	var t Task
+	var extra todoTxtExtra
+	words := strings.Fields(line)
+
+	if words[0] == "x" {
+		t.Completed = true
+		words = words[1:]
+		if len(words) > 0 && todoTxtDate.MatchString(words[0]) {
+			if at, err := time.ParseInLocation("2006-01-02", words[0], time.Local); err == nil {
+				t.CompletedAt = at.UTC().Format(time.RFC3339)
+			}
+			words = words[1:]
+		}
+	} else if m := todoTxtPriority.FindStringSubmatch(words[0]); m != nil {
+		t.Priority = todoTxtPriorityName(m[1])
+		words = words[1:]
+	}
+	if len(words) > 0 && todoTxtDate.MatchString(words[0]) {
+		extra.Created = words[0]
+		words = words[1:]
+	}
+
+	var text []string
+	for _, w := range words {
+		key, value, ok := strings.Cut(w, ":")
+		// Not times (10:30) or URLs: the key has to be a word
+		isKey := ok && todoTxtKey.MatchString(key) && value != "" && !strings.HasPrefix(value, "//")
+		switch {
+		case len(w) > 1 && (w[0] == '+' || w[0] == '@'):
+			tag := w
+			if w[0] == '+' {
+				tag = w[1:]
+			}
+			t.Tags = append(t.Tags, tag)
+		case isKey && key == "due" && todoTxtDate.MatchString(value):
+			t.DueDate = value
+		case isKey && key == "rec":
+			t.Recurring = recurrenceFromTodoTxt(value)
+		case isKey && key == "pri":
+			t.Priority = priorityFromTodoTxt(value)
+		case isKey:
+			extra.Keys = append(extra.Keys, w)
+		default:
+			text = append(text, w)
+		}
+	}
+	t.Text = strings.Join(text, " ")
+
+	if extra.Created != "" || len(extra.Keys) > 0 {
+		raw, _ := json.Marshal(extra)
+		t.Extra = map[string]json.RawMessage{"todotxt": raw}
+	}
+	return t
+}
+
+func todoTxtPriorityLetter(p string) string {
+	switch strings.ToLower(p) {
+	case "high":
+		return "A"
+	case "medium":
+		return "B"
+	case "low":
+		return "C"
+	}
+	if len(p) == 1 && p[0] >= 'A' && p[0] <= 'Z' {
+		return p
+	}
+	return ""
+}
+
+func todoTxtPriorityName(letter string) string {
+	switch letter {
+	case "A":
+		return "high"
+	case "B":
+		return "medium"
+	case "C":
+		return "low"
+	}
+	return letter
+}
+
+// priorityFromTodoTxt reads the value of a pri: key: a letter, or a
+// priority of any other name with its spaces written as _
+func priorityFromTodoTxt(value string) string {
+	if len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z' {
+		return todoTxtPriorityName(value)
+	}
+	return strings.ReplaceAll(value, "_", " ")
+}
+
+var todoTxtUnits = map[string][2]string{
+	"d": {"daily", "days"}, "w": {"weekly", "weeks"},
+	"m": {"monthly", "months"}, "y": {"yearly", "years"},
+}
+
+// recurrenceFromTodoTxt turns rec:1w into "weekly", rec:3d into "every 3
+// days" and so on. Strict rules (rec:+1w) are kept as they are.
+func recurrenceFromTodoTxt(rec string) string {
+	m := todoTxtRec.FindStringSubmatch(rec)
+	if m == nil {
+		return strings.ReplaceAll(rec, "_", " ")
+	}
+	if m[1] == "+" {
+		return rec
+	}
+	if m[3] == "b" {
+		if m[2] == "1" {
+			return "every weekday"
+		}
+		return rec
+	}
+	unit := todoTxtUnits[m[3]]
+	if m[2] == "1" {
+		return unit[0]
+	}
+	return "every " + m[2] + " " + unit[1]
+}
+
+// todoTxtRecurrence is the reverse of recurrenceFromTodoTxt
+func todoTxtRecurrence(rec string) string {
+	switch rec {
+	case "daily", "every day":
+		return "1d"
+	case "weekly", "every week":
+		return "1w"
+	case "monthly", "every month":
+		return "1m"
+	case "yearly", "every year":
+		return "1y"
+	case "every weekday":
+		return "1b"
+	}
+	if fields := strings.Fields(rec); len(fields) == 3 && fields[0] == "every" {
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			for short, unit := range todoTxtUnits {
+				if unit[1] == fields[2] {
+					return fields[1] + short
+				}
+			}
+		}
+	}
+	return strings.Join(strings.Fields(rec), "_")
+}
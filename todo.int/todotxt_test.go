@@ -0,0 +1,106 @@
+package todo
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	tests := []struct {
+		line      string
+		text      string
+		tags      []string
+		due       string
+		priority  string
+		recurring string
+		completed bool
+	}{
+		{
+			line: "(A) 2026-10-01 Call mom +family @phone due:2026-10-20",
+			text: "Call mom", tags: []string{"family", "@phone"}, due: "2026-10-20", priority: "high",
+		},
+		{
+			line: "x 2026-10-05 2026-10-01 Pay rent +home pri:A",
+			text: "Pay rent", tags: []string{"home"}, priority: "high", completed: true,
+		},
+		{
+			line: "x 2026-10-05 Sent invoice",
+			text: "Sent invoice", completed: true,
+		},
+		{
+			line: "Water plants due:2026-10-18 rec:1w",
+			text: "Water plants", due: "2026-10-18", recurring: "weekly",
+		},
+		{
+			line: "Stand-up every 2 days rec:2d",
+			text: "Stand-up every 2 days", recurring: "every 2 days",
+		},
+		{
+			line: "Meet at 10:30 about http://example.com/a:b",
+			text: "Meet at 10:30 about http://example.com/a:b",
+		},
+		{
+			line: "Review PR +work id:42 blocked-by:7",
+			text: "Review PR", tags: []string{"work"},
+		},
+		{
+			line: "Odd words like :colon and trailing: stay",
+			text: "Odd words like :colon and trailing: stay",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tasks, err := DecodeTodoTxt([]byte(tt.line + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 1 {
+				t.Fatalf("decoded %d tasks, want 1", len(tasks))
+			}
+			got := tasks[0]
+			if got.Text != tt.text || got.DueDate != tt.due || got.Priority != tt.priority ||
+				got.Recurring != tt.recurring || got.Completed != tt.completed {
+				t.Errorf("decoded %+v", got)
+			}
+			if !reflect.DeepEqual(got.Tags, tt.tags) {
+				t.Errorf("tags = %q, want %q", got.Tags, tt.tags)
+			}
+
+			out, err := EncodeTodoTxt(tasks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line := strings.TrimSuffix(string(out), "\n"); line != tt.line {
+				t.Errorf("encoded as\n  %s\nwant\n  %s", line, tt.line)
+			}
+		})
+	}
+}
+
+func TestTodoTxtCompletedWithoutCompletionTime(t *testing.T) {
+	created, _ := json.Marshal(todoTxtExtra{Created: "2026-10-01"})
+	task := Task{ID: 1, Text: "Old chore", Completed: true, Extra: map[string]json.RawMessage{"todotxt": created}}
+
+	out, err := EncodeTodoTxt([]Task{task})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Just the one date: no completion date is made up
+	if want := "x 2026-10-01 Old chore\n"; string(out) != want {
+		t.Errorf("encoded %q, want %q", out, want)
+	}
+	tasks, err := DecodeTodoTxt(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A lone date after x reads back as the completion date, as in any
+	// todo.txt tool
+	if len(tasks) != 1 || !tasks[0].Completed || tasks[0].Text != "Old chore" {
+		t.Fatalf("decoded %+v", tasks)
+	}
+	if at, ok := tasks[0].CompletedTime(); !ok || at.Local().Format("2006-01-02") != "2026-10-01" {
+		t.Errorf("completed %q, want on 2026-10-01", tasks[0].CompletedAt)
+	}
+}
@@ -0,0 +1,179 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trash keeps deleted tasks, with the time they were deleted, in a file
+// next to the task file until they are restored or purged
+type Trash struct {
+	Path       string
+	Encryption *Encryption
+}
+
+// TrashedTask is a deleted task waiting in the trash
+type TrashedTask struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	Task      Task      `json:"task"`
+}
+
+type trashFile struct {
+	Tasks []TrashedTask `json:"tasks"`
+}
+
+// TrashFor returns the trash that belongs to the task file at dataPath
+func TrashFor(dataPath string) *Trash {
+	return &Trash{Path: SidecarPath(dataPath, "trash.json")}
+}
+
+// Record moves tasks removed by delete or clear into the trash. Tasks that
+// reappear in the list (through undo, say) are taken back out of it.
+func (tr *Trash) Record(c Change) error {
+	var removed []Task
+	back := map[string]bool{}
+	for _, d := range c.Diffs() {
+		switch {
+		case d.After == nil && (c.Op == "delete" || c.Op == "clear"):
+			removed = append(removed, *d.Before)
+		case d.Before == nil:
+			back[d.After.UUID] = true
+		}
+	}
+	if len(removed) == 0 && len(back) == 0 {
+		return nil
+	}
+	// Nothing to take back from a trash that doesn't exist yet
+	if len(removed) == 0 {
+		if _, err := os.Stat(tr.Path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+
+	f, err := tr.load()
+	if err != nil {
+		return err
+	}
+	kept := f.Tasks[:0]
+	for _, t := range f.Tasks {
+		if !back[t.Task.UUID] {
+			kept = append(kept, t)
+		}
+	}
+	if len(removed) == 0 && len(kept) == len(f.Tasks) {
+		return nil
+	}
+	f.Tasks = kept
+	for _, t := range removed {
+		f.Tasks = append(f.Tasks, TrashedTask{DeletedAt: c.Time, Task: t})
+	}
+	return tr.save(f)
+}
+
+// List returns everything in the trash, oldest deletion first
+func (tr *Trash) List() ([]TrashedTask, error) {
+	f, err := tr.load()
+	return f.Tasks, err
+}
+
+// Restore puts a trashed task back in the list. input is matched like any
+// other task reference (ID, text or UUID prefix). The task keeps its
+// original ID unless another task has taken it since.
+func (tr *Trash) Restore(s Store, input string) (Task, error) {
+	var restored Task
+	err := Modify(s, "restore", func(tasks []Task) ([]Task, error) {
+		f, err := tr.load()
+		if err != nil {
+			return nil, err
+		}
+		trashed := make([]Task, len(f.Tasks))
+		for i, t := range f.Tasks {
+			trashed[i] = t.Task
+		}
+		i, err := ResolveTask(trashed, input)
+		if err != nil {
+			return nil, fmt.Errorf("not in trash: %s", input)
+		}
+
+		restored = f.Tasks[i].Task
+		if _, err := findByID(tasks, restored.ID); err == nil {
+			restored.ID = NextID(tasks)
+		}
+		f.Tasks = append(f.Tasks[:i], f.Tasks[i+1:]...)
+		if err := tr.save(f); err != nil {
+			return nil, err
+		}
+		return append(tasks, restored), nil
+	})
+	return restored, err
+}
+
+// Purge permanently drops trashed tasks deleted more than olderThan ago
+// (everything, if olderThan is zero) and returns how many went
+func (tr *Trash) Purge(s Store, olderThan time.Duration) (int, error) {
+	purged := 0
+	err := WithLock(s, func() error {
+		f, err := tr.load()
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-olderThan)
+		kept := f.Tasks[:0]
+		for _, t := range f.Tasks {
+			if olderThan == 0 || t.DeletedAt.Before(cutoff) {
+				purged++
+				continue
+			}
+			kept = append(kept, t)
+		}
+		f.Tasks = kept
+		return tr.save(f)
+	})
+	return purged, err
+}
+
+// ParseAge parses ages like "30d", "2w" or anything time.ParseDuration
+// accepts ("36h")
+func ParseAge(input string) (time.Duration, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(input, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(input, suffix))
+			if err != nil {
+				return 0, fmt.Errorf("invalid age: %s", input)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(input)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age: %s", input)
+	}
+	return d, nil
+}
+
+func (tr *Trash) load() (trashFile, error) {
+	var f trashFile
+	data, err := readSealed(tr.Path, tr.Encryption)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, nil
+		}
+		return f, err
+	}
+	err = json.Unmarshal(data, &f)
+	return f, err
+}
+
+func (tr *Trash) save(f trashFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSealed(tr.Path, data, tr.Encryption)
+}
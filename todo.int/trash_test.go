@@ -0,0 +1,146 @@
+package todo
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func trashTexts(t *testing.T, tr *Trash) []string {
+	t.Helper()
+	trashed, err := tr.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{}
+	for _, tt := range trashed {
+		texts = append(texts, tt.Task.Text)
+	}
+	return texts
+}
+
+func TestTrashRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	tr, h := TrashFor(path), HistoryFor(path)
+	s := Track(NewMemoryStore(), h, tr)
+	for _, text := range []string{"Buy milk", "Call mom", "Pay rent"} {
+		if err := AddTaskWithDueDate(s, text, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DeleteTask(s, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteTask(s, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := trashTexts(t, tr); !reflect.DeepEqual(got, []string{"Call mom", "Pay rent"}) {
+		t.Fatalf("trash = %q", got)
+	}
+
+	// The new task takes ID 2 while Call mom is in the trash
+	if err := AddTaskWithDueDate(s, "Water plants", ""); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := tr.Restore(s, "Pay rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != 3 {
+		t.Errorf("Pay rent came back as %d, want its old ID 3", restored.ID)
+	}
+	if restored, err = tr.Restore(s, "Call mom"); err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != 4 {
+		t.Errorf("Call mom came back as %d, want 4 with 2 taken", restored.ID)
+	}
+	if _, err := tr.Restore(s, "Pay rent"); err == nil || !strings.Contains(err.Error(), "not in trash") {
+		t.Errorf("restoring twice: %v, want not in trash", err)
+	}
+	if got := trashTexts(t, tr); len(got) != 0 {
+		t.Errorf("trash after restoring = %q", got)
+	}
+
+	// Clearing trashes everything; undoing the clear takes it all back out
+	if err := ClearTasks(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := trashTexts(t, tr); len(got) != 4 {
+		t.Errorf("trash after clear = %q, want all four", got)
+	}
+	if _, err := h.Undo(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := trashTexts(t, tr); len(got) != 0 {
+		t.Errorf("trash after undoing the clear = %q", got)
+	}
+	if got := taskTexts(t, s); len(got) != 4 {
+		t.Errorf("tasks after undoing the clear = %q", got)
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	tr := TrashFor(filepath.Join(t.TempDir(), "tasks.json"))
+	now := time.Now()
+	err := tr.save(trashFile{Tasks: []TrashedTask{
+		{DeletedAt: now.Add(-40 * 24 * time.Hour), Task: Task{ID: 1, Text: "Old"}},
+		{DeletedAt: now.Add(-10 * 24 * time.Hour), Task: Task{ID: 2, Text: "Recent"}},
+		{DeletedAt: now.Add(-time.Hour), Task: Task{ID: 3, Text: "Just now"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMemoryStore()
+
+	steps := []struct {
+		age  string
+		want int
+		left []string
+	}{
+		{"30d", 1, []string{"Recent", "Just now"}},
+		{"30d", 0, []string{"Recent", "Just now"}},
+		{"1w", 1, []string{"Just now"}},
+		{"0d", 1, []string{}},
+	}
+	for _, st := range steps {
+		age, err := ParseAge(st.age)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := tr.Purge(s, age)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != st.want {
+			t.Errorf("purge older than %s: %d purged, want %d", st.age, n, st.want)
+		}
+		if got := trashTexts(t, tr); !reflect.DeepEqual(got, st.left) {
+			t.Errorf("after purging older than %s: %q, want %q", st.age, got, st.left)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "30d", want: 30 * 24 * time.Hour},
+		{input: "2W", want: 14 * 24 * time.Hour},
+		{input: "36h", want: 36 * time.Hour},
+		{input: " 90m ", want: 90 * time.Minute},
+		{input: "xd", wantErr: true},
+		{input: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDAV is a WebDAV stand-in: enough GET, PUT and MKCOL for
+// WebDAVStore, with conditional PUTs
+type fakeDAV struct {
+	mu        sync.Mutex
+	files     map[string]davFile
+	dirs      map[string]bool
+	clock     time.Time
+	version   int
+	noETag    bool   // send no ETag headers
+	noMtime   bool   // send no Last-Modified headers
+	beforePut func() // runs (once) before the next PUT is handled
+}
+
+type davFile struct {
+	data     []byte
+	etag     string
+	modified time.Time
+}
+
+func newFakeDAV() *fakeDAV {
+	return &fakeDAV{files: map[string]davFile{}, dirs: map[string]bool{"/": true}, clock: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)}
+}
+
+// write stores a file as another client would
+func (d *fakeDAV) write(name string, data []byte) {
+	d.version++
+	// Whole seconds apart, as Last-Modified can't tell less apart
+	d.clock = d.clock.Add(time.Second)
+	d.files[name] = davFile{data: data, etag: fmt.Sprintf(`"v%d"`, d.version), modified: d.clock}
+}
+
+func (d *fakeDAV) setHeaders(w http.ResponseWriter, f davFile) {
+	if !d.noETag {
+		w.Header().Set("ETag", f.etag)
+	}
+	if !d.noMtime {
+		w.Header().Set("Last-Modified", f.modified.Format(http.TimeFormat))
+	}
+}
+
+func (d *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	hook := d.beforePut
+	if r.Method == http.MethodPut && hook != nil {
+		d.beforePut = nil
+		d.mu.Unlock()
+		hook()
+		d.mu.Lock()
+	}
+	defer d.mu.Unlock()
+
+	f, exists := d.files[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		d.setHeaders(w, f)
+		w.Write(f.data)
+	case http.MethodPut:
+		if !d.dirs[path.Dir(r.URL.Path)+"/"] && path.Dir(r.URL.Path) != "/" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		ifMatch, ifNone := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		since, _ := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+		switch {
+		case ifNone == "*" && exists,
+			ifMatch != "" && (!exists || ifMatch != f.etag),
+			!since.IsZero() && (!exists || f.modified.After(since)):
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		d.write(r.URL.Path, data)
+		d.setHeaders(w, d.files[r.URL.Path])
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "MKCOL":
+		d.dirs[r.URL.Path] = true
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestWebDAVStore(t *testing.T, d *fakeDAV, remotePath string) *WebDAVStore {
+	t.Helper()
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	s, err := NewWebDAVStore(strings.Replace(srv.URL, "http://", "webdav+http://", 1)+remotePath, "tasks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestWebDAVStoreFirstSaveAndReload(t *testing.T) {
+	d := newFakeDAV()
+	s := newTestWebDAVStore(t, d, "/dav/todo/")
+
+	tasks, err := s.Load()
+	if err != nil || len(tasks) != 0 {
+		t.Fatalf("Load of a missing file = %v, %v; want no tasks", tasks, err)
+	}
+	if err := AddTaskWithDueDate(s, "Buy milk", ""); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	if !d.dirs["/dav/todo/"] {
+		t.Error("the missing collection wasn't created")
+	}
+	if err := AddTaskWithDueDate(s, "Call mom", ""); err != nil {
+		t.Fatalf("second save: %v", err)
+	}
+
+	other := newTestWebDAVStore(t, d, "/dav/todo/tasks.json")
+	if got := taskTexts(t, other); len(got) != 2 || got[0] != "Buy milk" || got[1] != "Call mom" {
+		t.Errorf("remote holds %q", got)
+	}
+}
+
+func TestWebDAVStoreRefusesToOverwrite(t *testing.T) {
+	tests := []struct {
+		name            string
+		noETag, noMtime bool
+		wantErr         string // instead of ErrRemoteChanged
+	}{
+		{name: "ETag"},
+		{name: "Last-Modified only", noETag: true},
+		{name: "neither", noETag: true, noMtime: true, wantErr: "gives no ETag or Last-Modified"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDAV()
+			d.noETag, d.noMtime = tt.noETag, tt.noMtime
+			d.write("/tasks.json", []byte(`{"version":2,"tasks":[]}`))
+			s := newTestWebDAVStore(t, d, "/tasks.json")
+
+			if _, err := s.Load(); err != nil {
+				t.Fatal(err)
+			}
+			d.write("/tasks.json", []byte(`{"version":2,"tasks":[{"id":1,"text":"theirs","completed":false}]}`))
+			err := s.Save([]Task{{ID: 1, Text: "mine"}})
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Save = %v, want %q", err, tt.wantErr)
+				}
+			case !errors.Is(err, ErrRemoteChanged):
+				t.Errorf("Save over a changed copy = %v, want ErrRemoteChanged", err)
+			}
+			if string(d.files["/tasks.json"].data) == "" || strings.Contains(string(d.files["/tasks.json"].data), "mine") {
+				t.Error("the other client's copy was overwritten")
+			}
+
+			if tt.wantErr != "" {
+				return
+			}
+			// Saving after loading again goes through
+			if _, err := s.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save([]Task{{ID: 1, Text: "mine"}}); err != nil {
+				t.Errorf("Save after reloading: %v", err)
+			}
+		})
+	}
+}
+
+func TestSyncWebDAVRetriesOnConcurrentChange(t *testing.T) {
+	d := newFakeDAV()
+	remote := newTestWebDAVStore(t, d, "/tasks.json")
+	if err := AddTaskWithDueDate(remote, "Pay rent", ""); err != nil {
+		t.Fatal(err)
+	}
+	local := NewMemoryStore()
+	if err := AddTaskWithDueDate(local, "Buy milk", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another client adds a task just as the sync writes
+	otherClient := newTestWebDAVStore(t, d, "/tasks.json")
+	d.beforePut = func() {
+		if err := AddTaskWithDueDate(otherClient, "Call mom", ""); err != nil {
+			t.Error(err)
+		}
+	}
+	state := SyncStateFor(filepath.Join(t.TempDir(), "tasks.json"))
+	res, err := Sync(local, remote, state, "dav", func(Conflict) Side { return Local })
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if res.Pushed != 1 || res.Pulled != 2 {
+		t.Errorf("result %+v, want 1 pushed, 2 pulled", res)
+	}
+	want := map[string]bool{"Buy milk": true, "Pay rent": true, "Call mom": true}
+	for name, s := range map[string]Store{"local": local, "remote": otherClient} {
+		got := taskTexts(t, s)
+		if len(got) != len(want) {
+			t.Errorf("%s holds %q, want all three tasks", name, got)
+		}
+		for _, text := range got {
+			if !want[text] {
+				t.Errorf("%s holds unexpected %q", name, text)
+			}
+		}
+	}
+}